@@ -10,10 +10,12 @@ import (
 	"github.com/Domingor/go-blackbox/server/cache"
 	"github.com/Domingor/go-blackbox/server/cronjobs"
 	"github.com/Domingor/go-blackbox/server/datasource"
+	"github.com/Domingor/go-blackbox/server/datasource/migrate"
 	"github.com/Domingor/go-blackbox/server/mongodb"
 	"github.com/Domingor/go-blackbox/server/webiris"
 	log "github.com/Domingor/go-blackbox/server/zaplog"
 	"github.com/Domingor/go-blackbox/simpleioc"
+	"io/fs"
 	"net/http"
 	"time"
 )
@@ -25,15 +27,17 @@ const (
 
 // ApplicationBuilder app builder接口提供系统初始化服务基础功能
 type ApplicationBuilder interface {
-	EnableWeb(timeFormat, port, logLevel string, components webiris.PartyComponent) *ApplicationBuild // 启动web服务
-	EnableDb(dbConfig *datasource.PostgresConfig, models ...interface{}) *ApplicationBuild            // 启动数据库
-	EnableCache(redConfig cache.RedisOptions) *ApplicationBuild                                       // 启动缓存
-	LoadConfig(configStruct interface{}, loaderFun func(apploader.Loader)) error                      // 加载配置文件、环境变量等
-	InitLog(outDirPath, level string) *ApplicationBuild                                               // 初始化日志打印
-	EnableMongoDB(dbConfig *mongodb.MongoDBConfig) *ApplicationBuild                                  // 启动缓存数据库
-	InitCronJob() *ApplicationBuild                                                                   // 初始化定时任务
-	SetupToken(AMinute, RHour time.Duration, TokenIssuer string) *ApplicationBuild                    // 配置web-token属性
-	EnableStaticSource(file embed.FS) *ApplicationBuild                                               // 加载静态资源
+	EnableWeb(timeFormat, port, logLevel string, components webiris.PartyComponent) *ApplicationBuild        // 启动web服务
+	EnableDb(dbConfig *datasource.DataSourceConfig, models ...interface{}) *ApplicationBuild                 // 启动数据库，多次调用按别名注册多个数据源
+	EnableMigration(alias string, fsys fs.FS, dir string, migrations ...migrate.Migration) *ApplicationBuild // 注册数据源的版本化迁移
+	EnableCache(redConfig *cache.RedisConfig) *ApplicationBuild                                              // 启动缓存
+	LoadConfig(configStruct interface{}, loaderFun func(apploader.Loader)) error                             // 加载配置文件、环境变量等
+	WatchConfig() *ApplicationBuild                                                                          // 监听配置文件变化并同步配置定义的定时任务
+	InitLog(outDirPath, level string) *ApplicationBuild                                                      // 初始化日志打印
+	EnableMongoDB(dbConfig *mongodb.MongoDBConfig) *ApplicationBuild                                         // 启动缓存数据库
+	InitCronJob(cronConfig ...*cronjobs.CronConfig) *ApplicationBuild                                        // 初始化定时任务
+	SetupToken(AMinute, RHour time.Duration, TokenIssuer string) *ApplicationBuild                           // 配置web-token属性
+	EnableStaticSource(file embed.FS) *ApplicationBuild                                                      // 加载静态资源
 	// TODO ...more functions
 }
 
@@ -43,16 +47,23 @@ type ApplicationBuild struct {
 	irisApp webiris.WebBaseFunc
 	// 启动种子list集合
 	seeds []seed.SeedFunc
-	// 数据库配置
-	dbConfig *datasource.PostgresConfig
-	// 注册表模块-tables
-	dbModels []interface{}
+	// 数据源配置及各自注册的表模块-tables，按调用 EnableDb 的顺序初始化
+	dataSources []dataSource
+	// 各数据源的版本化迁移，key为数据源别名
+	migrations map[string]migrationSource
 	// 上下文对象
 	ctx context.Context
 	// redis配置对象
-	redisOptions cache.RedisOptions
+	redisConfig *cache.RedisConfig
 	// MongoDB
 	mongoBbConfig *mongodb.MongoDBConfig
+	// 定时任务配置，配置文件重新加载后同步配置定义的任务
+	cronConfig *cronjobs.CronConfig
+	// LoadConfig 使用的加载器及配置结构体，开启监听时使用
+	configLoader apploader.Loader
+	configStruct interface{}
+	// 是否监听配置文件变化
+	isWatchingConfig bool
 	//=========================================》 启动标识
 	// 是否启动定时服务，在enableCronjob后为true，会自动start()，即开始调用定时Cron表达式函数
 	IsRunningCronJob bool
@@ -97,22 +108,48 @@ func (app *ApplicationBuild) EnableWeb(timeFormat, port, logLevel string, compon
 	return app
 }
 
-// EnableDb 启动数据库操作对象
-func (app *ApplicationBuild) EnableDb(dbConfig *datasource.PostgresConfig, models ...interface{}) *ApplicationBuild {
+// dataSource 单个数据源的配置和表模块
+type dataSource struct {
+	config *datasource.DataSourceConfig
+	models []interface{}
+}
+
+// EnableDb 启动数据库操作对象，按 AliasName 区分数据源，多次调用可连接多个数据库，
+// 通过 GormDb(alias) 获取，未设置别名的为 default 数据源
+func (app *ApplicationBuild) EnableDb(dbConfig *datasource.DataSourceConfig, models ...interface{}) *ApplicationBuild {
 	//开启 db
 	app.IsEnableDB = true
 
-	app.dbConfig = dbConfig
+	app.dataSources = append(app.dataSources, dataSource{config: dbConfig, models: models})
+	return app
+}
 
-	app.dbModels = models
+// migrationSource 迁移文件目录及Go迁移
+type migrationSource struct {
+	fsys       fs.FS
+	dir        string
+	migrations []migrate.Migration
+}
+
+// EnableMigration 注册数据源的版本化迁移，fsys 目录下为 0001_name.up.sql、0001_name.down.sql 格式的文件，可为nil只使用Go迁移。
+// 数据源配置 InitDb 为true时启动后先执行未执行的迁移，再初始化 EnableDb 注册的model表，
+// 也可通过 migrate 命令行参数手动执行，migrate 命令不执行 AutoMigrate
+func (app *ApplicationBuild) EnableMigration(alias string, fsys fs.FS, dir string, migrations ...migrate.Migration) *ApplicationBuild {
+	if alias == "" {
+		alias = datasource.DefaultAlias
+	}
+	if app.migrations == nil {
+		app.migrations = make(map[string]migrationSource)
+	}
+	app.migrations[alias] = migrationSource{fsys: fsys, dir: dir, migrations: migrations}
 	return app
 }
 
 // EnableCache 启动缓存
-func (app *ApplicationBuild) EnableCache(redConfig cache.RedisOptions) *ApplicationBuild {
+func (app *ApplicationBuild) EnableCache(redConfig *cache.RedisConfig) *ApplicationBuild {
 	app.IsEnableCache = true
 
-	app.redisOptions = redConfig
+	app.redisConfig = redConfig
 	return app
 }
 
@@ -128,8 +165,30 @@ func (app *ApplicationBuild) LoadConfig(configStruct interface{}, loaderFun func
 	loaderFun(loader)
 
 	// 读取到的属性值赋值给配置对象
-	err := loader.LoadToStruct(configStruct)
-	return err
+	if err := loader.LoadToStruct(configStruct); err != nil {
+		return err
+	}
+
+	// 调用 WatchConfig 后，服务启动完成时开始监听配置文件
+	app.configLoader, app.configStruct = loader, configStruct
+	return nil
+}
+
+// WatchConfig 开启配置文件监听，服务启动完成后配置文件修改时重新加载 LoadConfig 的配置结构体，
+// 并同步配置定义的定时任务；未调用 LoadConfig 时不生效
+func (app *ApplicationBuild) WatchConfig() *ApplicationBuild {
+	app.isWatchingConfig = true
+	return app
+}
+
+// onConfigChange 配置文件重新加载后的回调，同步配置定义的定时任务
+func (app *ApplicationBuild) onConfigChange() {
+	log.SugaredLogger.Info("config reloaded")
+	if app.IsRunningCronJob {
+		if err := cronjobs.RegistryInstance().ApplyConfig(app.cronConfig.Jobs); err != nil {
+			log.SugaredLogger.Errorf("apply cron jobs config failed, keep previous jobs: %s", err)
+		}
+	}
 }
 
 // InitLog 初始化自定义日志
@@ -164,13 +223,17 @@ func (app *ApplicationBuild) EnableMongoDB(dbConfig *mongodb.MongoDBConfig) *App
 	return app
 }
 
-// InitCronJob 初始化定时任务对象，存放入IOC
-func (app *ApplicationBuild) InitCronJob() *ApplicationBuild {
+// InitCronJob 初始化定时任务对象，存放入IOC，可选传入定时任务配置
+func (app *ApplicationBuild) InitCronJob(cronConfig ...*cronjobs.CronConfig) *ApplicationBuild {
 	// 设置启动定时任务
 	app.IsRunningCronJob = true
+	app.cronConfig = &cronjobs.CronConfig{}
+	if len(cronConfig) > 0 && cronConfig[0] != nil {
+		app.cronConfig = cronConfig[0]
+	}
 
-	// 定时任务客户端放入容器
-	simpleioc.Set(cronjobs.CronInstance())
+	// 定时任务客户端、命名任务注册表放入容器
+	simpleioc.Set(cronjobs.CronInstance(), cronjobs.RegistryInstance())
 
 	return app
 }
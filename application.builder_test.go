@@ -36,10 +36,10 @@ func TestWeb(t *testing.T) {
 			//	MaxIdleConns: 20,
 			//	MaxOpenConns: 10,
 			//}
-			//redConfig := cache.RedisOptions{
+			//redConfig := &cache.RedisConfig{
 			//	Addr:     "127.0.0.1:6380",
 			//	Password: "123456",
-			//	DB:       0,
+			//	Db:       0,
 			//}
 
 			builder.
@@ -3,15 +3,21 @@ package appbox
 import (
 	"context"
 	"errors"
+	"flag"
+	"fmt"
 	"github.com/Domingor/go-blackbox/seed"
 	"github.com/Domingor/go-blackbox/server/cache"
+	"github.com/Domingor/go-blackbox/server/cronjobs"
 	"github.com/Domingor/go-blackbox/server/datasource"
+	"github.com/Domingor/go-blackbox/server/datasource/migrate"
+	"github.com/Domingor/go-blackbox/server/lock"
 	"github.com/Domingor/go-blackbox/server/mongodb"
 	"github.com/Domingor/go-blackbox/server/shutdown"
 	log "github.com/Domingor/go-blackbox/server/zaplog"
 	"github.com/Domingor/go-blackbox/simpleioc"
 	"github.com/robfig/cron/v3"
 	"gorm.io/gorm"
+	"os"
 	"sync"
 	"time"
 )
@@ -51,6 +57,10 @@ func New() Application {
 
 // Start 全局启动配置器，初始化个个服务配置信息
 func (app *application) Start(builderFun func(ctx context.Context, builder *ApplicationBuild) error) (err error) {
+	// migrate 命令：只初始化数据源并执行迁移，不启动其他服务
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		return app.migrateCommand(builderFun, os.Args[2:])
+	}
 
 	// 开始执行构建服务程序
 	if err = app.buildingService(builderFun); err == nil {
@@ -62,6 +72,10 @@ func (app *application) Start(builderFun func(ctx context.Context, builder *Appl
 			BeforeExit: func(s string) {
 				// 收到消息-开始执行钩子函数
 				log.SugaredLogger.Info(s)
+				// 停止调度并等待正在执行的定时任务结束
+				if app.builder.IsRunningCronJob {
+					<-CronJobSingle().Stop().Done()
+				}
 			},
 		})
 	}
@@ -90,24 +104,23 @@ func (app *application) buildingService(builderFun func(ctx context.Context, bui
 	// TODO others services that needed to be handled.
 	// TODO needed to be writed down here.
 
-	// 1. 数据库
-	if app.builder.IsEnableDB {
-		// 初始化数据，注册模型
-		if err = datasource.GormInit(app.builder.dbConfig, app.builder.dbModels); err != nil {
-			log.SugaredLogger.Debugf("init db service error %s", err)
+	// 1. cache，先于数据库初始化，供查询缓存使用
+	if app.builder.IsEnableCache {
+		// 初始化缓存，放入容器
+		cacher, err := cache.Init(simpleioc.GetContext().Ctx, app.builder.redisConfig)
+		if err != nil {
+			log.SugaredLogger.Debugf("init cache service error %s", err)
 			return err
 		}
-
-		// 放入ioc
-		instance, _ := datasource.GetDbInstance()
-		//放入ioc容器
-		simpleioc.Set(instance)
+		simpleioc.Set(cacher)
+		datasource.UseCache(cacher)
 	}
 
-	//2. cache
-	if app.builder.IsEnableCache {
-		// 初始化redis，放入容器
-		simpleioc.Set(cache.Init(simpleioc.GetContext().Ctx, app.builder.redisOptions))
+	// 2. 数据库
+	if app.builder.IsEnableDB {
+		if err = app.openDataSources(simpleioc.GetContext().Ctx, true); err != nil {
+			return err
+		}
 	}
 	//3. MongoDb
 	if app.builder.IsEnableMongoDB {
@@ -165,9 +178,101 @@ func (app *application) buildingService(builderFun func(ctx context.Context, bui
 	return
 }
 
-// GormDb 获取操作数据库-Gorm实例
-func GormDb() *gorm.DB {
-	return simpleioc.GetDb()
+// openDataSources 按别名注册各数据源，migrateOnStart 为true时先执行 InitDb 数据源未执行的版本化迁移，
+// 再按 SchemaPolicy 初始化model表，ctx用于执行迁移；migrate 命令传false，只打开连接，表结构完全由迁移命令管理
+func (app *application) openDataSources(ctx context.Context, migrateOnStart bool) error {
+	for _, ds := range app.builder.dataSources {
+		db, err := datasource.Open(ds.config)
+		if err != nil {
+			log.SugaredLogger.Debugf("init db service error %s", err)
+			return err
+		}
+		if !migrateOnStart {
+			continue
+		}
+		if _, ok := app.builder.migrations[ds.config.Alias()]; ok && ds.config.InitDb {
+			m, err := app.migrator(ds.config.Alias())
+			if err != nil {
+				return err
+			}
+			if _, err = m.Up(ctx); err != nil {
+				log.SugaredLogger.Debugf("migrate db %s error %s", ds.config.Alias(), err)
+				return err
+			}
+		}
+		if err = datasource.MigrateModels(db, ds.config, ds.models...); err != nil {
+			return err
+		}
+	}
+
+	// 默认数据源放入ioc
+	instance, _ := datasource.GetDbInstance()
+	//放入ioc容器
+	simpleioc.Set(instance)
+	return nil
+}
+
+// migrator 创建数据源的迁移执行器
+func (app *application) migrator(alias string) (*migrate.Migrator, error) {
+	source, ok := app.builder.migrations[alias]
+	if !ok {
+		return nil, fmt.Errorf("no migration registered for db %s", alias)
+	}
+	db, err := datasource.GetDb(alias)
+	if err != nil {
+		return nil, err
+	}
+	migrations, err := migrate.Load(source.fsys, source.dir, source.migrations...)
+	if err != nil {
+		return nil, err
+	}
+	return migrate.New(db, migrations), nil
+}
+
+// migrateCommand 执行迁移命令，如 app migrate -db report down 2
+func (app *application) migrateCommand(builderFun func(ctx context.Context, builder *ApplicationBuild) error, args []string) (err error) {
+	flags := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	alias := flags.String("db", datasource.DefaultAlias, "datasource alias")
+	flags.Usage = func() {
+		_, _ = fmt.Fprintln(flags.Output(), migrate.Usage)
+		flags.PrintDefaults()
+	}
+	if err = flags.Parse(args); err != nil {
+		return err
+	}
+
+	if builderFun == nil {
+		return errors.New("builderFun is not a expected function for building")
+	}
+	if err = builderFun(simpleioc.GetContext().Ctx, app.builder); err != nil {
+		return err
+	}
+	if !app.builder.IsEnableZapLogs {
+		app.builder.InitLog(".", "debug")
+	}
+	if !app.builder.IsEnableDB {
+		return errors.New("db is not enabled")
+	}
+	if err = app.openDataSources(simpleioc.GetContext().Ctx, false); err != nil {
+		return err
+	}
+	m, err := app.migrator(*alias)
+	if err != nil {
+		return err
+	}
+	return migrate.Command(simpleioc.GetContext().Ctx, m, flags.Args(), os.Stdout)
+}
+
+// GormDb 获取操作数据库-Gorm实例，不传别名时返回默认数据源，别名未注册时返回nil
+func GormDb(alias ...string) *gorm.DB {
+	if len(alias) == 0 {
+		return simpleioc.GetDb()
+	}
+	db, err := datasource.GetDb(alias...)
+	if err != nil {
+		log.SugaredLogger.Debugf("get db failed %s", err)
+	}
+	return db
 }
 
 // GlobalCtx 获取context上下文
@@ -185,6 +290,11 @@ func CronJobSingle() *cron.Cron {
 	return simpleioc.GetCronJobInstance()
 }
 
+// CronJobs 获取命名定时任务注册表
+func CronJobs() *cronjobs.Registry {
+	return cronjobs.RegistryInstance()
+}
+
 // MongoDb 获取MongoDB实例
 func MongoDb() *mongodb.Client {
 	return simpleioc.GetMongoDb()
@@ -200,11 +310,64 @@ func afterDoSomething() (err error) {
 
 	// 执行定时任务
 	if app.builder.IsRunningCronJob {
+		if err = setupCronJobs(); err != nil {
+			log.SugaredLogger.Debug("setup cron jobs failed,", err)
+			return
+		}
 		CronJobSingle().Start()
 	}
+
+	// 定时任务按初始配置注册后再监听配置文件，避免重新加载与初始化并发
+	if app.builder.isWatchingConfig && app.builder.configLoader != nil {
+		app.builder.configLoader.WatchConfig(app.builder.configStruct, app.builder.onConfigChange)
+	}
 	return err
 }
 
+// setupCronJobs 按已启用的服务配置命名任务：集群协调、执行记录存储及过期记录清理、配置定义的任务、一次性任务持久化
+func setupCronJobs() error {
+	registry := CronJobs()
+	// 进程退出时取消正在执行的任务
+	registry.UseContext(simpleioc.GetContext().Ctx)
+	if locker := clusterLocker(); locker != nil {
+		registry.UseLocker(simpleioc.GetContext().Ctx, locker)
+	}
+
+	var db *gorm.DB
+	if app.builder.IsEnableDB {
+		db = GormDb()
+	}
+	if err := registry.ConfigureHistory(app.builder.cronConfig.History, db); err != nil {
+		return err
+	}
+	if err := registry.ApplyConfig(app.builder.cronConfig.Jobs); err != nil {
+		return err
+	}
+	if db == nil || !app.builder.cronConfig.PersistTasks {
+		return nil
+	}
+	store, err := cronjobs.NewGormTaskStore(db)
+	if err != nil {
+		return err
+	}
+	return registry.UseTaskStore(simpleioc.GetContext().Ctx, store)
+}
+
+// clusterLocker 按已启用的服务选择定时任务集群锁：优先redis，其次postgres advisory lock，都不可用时返回nil
+func clusterLocker() cronjobs.Locker {
+	if app.builder.IsEnableCache {
+		if locker, err := lock.FromCache(RedisCache()); err == nil {
+			return cronjobs.NewRedisLocker(locker)
+		}
+	}
+	if app.builder.IsEnableDB && GormDb().Dialector.Name() == datasource.DriverPostgres {
+		if sqlDB, err := GormDb().DB(); err == nil {
+			return cronjobs.NewPgLocker(sqlDB)
+		}
+	}
+	return nil
+}
+
 /*
 
 
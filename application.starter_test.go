@@ -0,0 +1,56 @@
+package appbox
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/Domingor/go-blackbox/server/datasource"
+	"github.com/Domingor/go-blackbox/server/datasource/migrate"
+	log "github.com/Domingor/go-blackbox/server/zaplog"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+func TestOpenDataSources(t *testing.T) {
+	oldLogger, oldSugared := log.Logger, log.SugaredLogger
+	log.Logger = zap.NewNop()
+	log.SugaredLogger = log.Logger.Sugar()
+	t.Cleanup(func() { log.Logger, log.SugaredLogger = oldLogger, oldSugared })
+
+	// 迁移建表，AutoMigrate 先执行时建表迁移会失败
+	createUser := migrate.Migration{Version: 1, Name: "create_user", Up: func(tx *gorm.DB) error {
+		return tx.Exec("CREATE TABLE user (id integer primary key, legacy text)").Error
+	}}
+	starter := func(alias string) (*application, *datasource.DataSourceConfig) {
+		config := &datasource.DataSourceConfig{
+			Driver:    datasource.DriverSqlite,
+			DbName:    filepath.Join(t.TempDir(), "app.db"),
+			AliasName: alias,
+			InitDb:    true,
+		}
+		a := &application{builder: &ApplicationBuild{}}
+		a.builder.EnableDb(config, &User{}).EnableMigration(alias, nil, "", createUser)
+		t.Cleanup(func() { _ = datasource.Close(alias) })
+		return a, config
+	}
+
+	a, _ := starter("starter")
+	if err := a.openDataSources(context.Background(), true); err != nil {
+		t.Fatal(err)
+	}
+	db, _ := datasource.GetDb("starter")
+	if !db.Migrator().HasColumn(&User{}, "legacy") || !db.Migrator().HasColumn(&User{}, "age") {
+		t.Error("migrations should run before AutoMigrate")
+	}
+
+	// migrate 命令只打开连接，不执行迁移和 AutoMigrate
+	a, _ = starter("migrate")
+	if err := a.openDataSources(context.Background(), false); err != nil {
+		t.Fatal(err)
+	}
+	db, _ = datasource.GetDb("migrate")
+	if db.Migrator().HasTable(&User{}) {
+		t.Error("migrate command should not create tables")
+	}
+}
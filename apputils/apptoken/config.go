@@ -0,0 +1,10 @@
+package apptoken
+
+import "time"
+
+// TokenConfig token配置
+type TokenConfig struct {
+	AccessExpire  time.Duration `mapstructure:"accessExpire" json:"accessExpire" yaml:"accessExpire"`    // access token有效期，如 30m
+	RefreshExpire time.Duration `mapstructure:"refreshExpire" json:"refreshExpire" yaml:"refreshExpire"` // refresh token有效期，如 168h
+	Issuer        string        `mapstructure:"issuer" json:"issuer" yaml:"issuer"`                      // token签发者
+}
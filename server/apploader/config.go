@@ -1,44 +1,56 @@
 package apploader
 
+import (
+	"fmt"
+	"github.com/Domingor/go-blackbox/apputils/apptoken"
+	"github.com/Domingor/go-blackbox/server/cache"
+	"github.com/Domingor/go-blackbox/server/cronjobs"
+	"github.com/Domingor/go-blackbox/server/datasource"
+	"github.com/Domingor/go-blackbox/server/email"
+	"github.com/Domingor/go-blackbox/server/mongodb"
+	"github.com/Domingor/go-blackbox/server/rabbitmqretry/rabbitmq"
+	"github.com/Domingor/go-blackbox/server/webiris"
+	"github.com/Domingor/go-blackbox/server/zaplog"
+	"sort"
+)
+
 var Config Configuration
 
+// Configuration 内置组件完整配置，各配置项直接映射到组件自身的配置结构体
 type Configuration struct {
-	Name string `toml:"name"`
-
-	Version string `toml:"version"`
-
-	Web     web     `toml:"web"`
-	Db      db      `toml:"db"`
-	Redis   redis   `toml:"redis"`
-	LogConf logConf `toml:"logConf"`
+	Name string `mapstructure:"name" json:"name" yaml:"name"`
+
+	Version string `mapstructure:"version" json:"version" yaml:"version"`
+
+	Web      webiris.WebConfig                      `mapstructure:"web" json:"web" yaml:"web"`                   // web服务
+	Db       datasource.DataSourceConfig            `mapstructure:"db" json:"db" yaml:"db"`                      // 数据库
+	Dbs      map[string]datasource.DataSourceConfig `mapstructure:"dbs" json:"dbs" yaml:"dbs"`                   // 其他数据源，key为别名，通过 DbConfigs 获取
+	Redis    cache.RedisConfig                      `mapstructure:"redis" json:"redis" yaml:"redis"`             // 缓存
+	RabbitMq rabbitmq.QueueExchange                 `mapstructure:"rabbitmq" json:"rabbitmq" yaml:"rabbitmq"`    // 消息队列
+	MongoDb  mongodb.MongoDBConfig                  `mapstructure:"mongodb" json:"mongodb" yaml:"mongodb"`       // MongoDB
+	LogConf  zaplog.Zap                             `mapstructure:"logConfig" json:"logConfig" yaml:"logConfig"` // 日志
+	Email    email.MailConnConf                     `mapstructure:"email" json:"email" yaml:"email"`             // 邮件
+	Token    apptoken.TokenConfig                   `mapstructure:"token" json:"token" yaml:"token"`             // web-token
+	Cron     cronjobs.CronConfig                    `mapstructure:"cron" json:"cron" yaml:"cron"`                // 定时任务
 }
 
-type web struct {
-	Listen     string `toml:"listen"`
-	DebugLevel string `toml:"debugLevel"`
-}
-
-type db struct {
-	User     string `toml:"user"`
-	Password string `toml:"password"`
-	Host     string `toml:"host"`
-	Port     int    `toml:"port"`
-	DbName   string `toml:"dbName"`
-	Ssl      string `toml:"ssl"`
-
-	MaxIdleConns int `toml:"maxIdleConns"`
-	MaxOpenConns int `toml:"maxOpenConns"`
-}
-
-type redis struct {
-	Adders   string `toml:"addrs"`
-	Password string `toml:"password"`
-	PoolSize int    `toml:"poolSize"`
-	Db       int    `toml:"db"`
-}
-
-type logConf struct {
-	OutDirPath string `toml:"outDirPath"`
-
-	LogLevel string `toml:"logLevel"`
+// DbConfigs 按别名排序返回其他数据源配置，AliasName 设为 dbs 中的key，
+// 配置中已填写的 aliasName 与key不一致时返回错误
+func (c *Configuration) DbConfigs() ([]*datasource.DataSourceConfig, error) {
+	keys := make([]string, 0, len(c.Dbs))
+	for key := range c.Dbs {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	configs := make([]*datasource.DataSourceConfig, 0, len(keys))
+	for _, key := range keys {
+		config := c.Dbs[key]
+		if config.AliasName != "" && config.AliasName != key {
+			return nil, fmt.Errorf("dbs.%s: aliasName %s does not match key", key, config.AliasName)
+		}
+		config.AliasName = key
+		configs = append(configs, &config)
+	}
+	return configs, nil
 }
@@ -2,10 +2,13 @@ package apploader
 
 import (
 	"github.com/fatih/structs"
+	"github.com/fsnotify/fsnotify"
 	"github.com/jeremywohl/flatten"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
+	"reflect"
 	"strings"
+	"sync"
 )
 
 // Loader 定义加载器-解析配置文件
@@ -13,12 +16,15 @@ type Loader interface {
 	LoadToStruct(config interface{}) error                                // 将解析的配置文件值、环境变量值映射到 配置结构体中
 	SetConfigFileSearcher(configName string, searchPath ...string) Loader // 设置配置文件名称，路径多个
 	EnableEnvSearcher(envPrefix string) Loader                            // 开启读取环境变量，设置环境变量前缀可选
+	WatchConfig(config interface{}, onChange func()) Loader               // 监听配置文件变化，重新映射到配置结构体后回调
+	RLocker() sync.Locker                                                 // 配置重新加载时持有写锁，与监听协程并发读取配置结构体时加读锁
 }
 
 // 配置加载器
 type loader struct {
 	vConf           *viper.Viper
 	envSearchEnable bool
+	mu              sync.RWMutex // 保护重新加载时配置结构体的写入
 }
 
 // NewLoader 初始化配置
@@ -75,6 +81,39 @@ func (t *loader) prepareEnv(target interface{}) Loader {
 	return t
 }
 
+// WatchConfig config须为结构体指针；变化后解析到新的零值结构体，再加写锁整体复制到config，
+// 文件中删除的配置项恢复为零值，而不是保留旧值
+func (lo *loader) WatchConfig(config interface{}, onChange func()) Loader {
+	// 未读取到配置文件时无需监听
+	if lo.vConf.ConfigFileUsed() == "" {
+		return lo
+	}
+	target := reflect.ValueOf(config)
+	if target.Kind() != reflect.Ptr || target.IsNil() {
+		logrus.Errorf("watch config failed: config must be a non-nil pointer, got %T", config)
+		return lo
+	}
+	lo.vConf.OnConfigChange(func(e fsnotify.Event) {
+		fresh := reflect.New(target.Elem().Type())
+		if err := lo.LoadToStruct(fresh.Interface()); err != nil {
+			logrus.Errorf("reload config %s failed: %s", e.Name, err)
+			return
+		}
+		lo.mu.Lock()
+		target.Elem().Set(fresh.Elem())
+		lo.mu.Unlock()
+		if onChange != nil {
+			onChange()
+		}
+	})
+	lo.vConf.WatchConfig()
+	return lo
+}
+
+func (lo *loader) RLocker() sync.Locker {
+	return lo.mu.RLocker()
+}
+
 func (lo *loader) LoadToStruct(config interface{}) (err error) {
 
 	// 开启环境变量读取
@@ -1,5 +1,12 @@
 package apploader
 
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
 //func TestLoadConf(t *testing.T) {
 //o := NewLoader()
 //o.SetConfigFileSearcher("config", "../../")
@@ -60,3 +67,59 @@ package apploader
 //	fmt.Printf("st 原始切片的地址为：%p\n", slice)
 //	*slice = append(*slice, 1)
 //}
+
+func TestWatchConfig(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "app.toml")
+	if err := os.WriteFile(file, []byte("[cron]\nenable = false\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var conf Configuration
+	changed := make(chan struct{}, 1)
+	loader := NewLoader().SetConfigFileSearcher("app", dir)
+	if err := loader.LoadToStruct(&conf); err != nil || conf.Cron.Enable {
+		t.Fatalf("unexpected config %+v %v", conf.Cron, err)
+	}
+	loader.WatchConfig(&conf, func() {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	})
+
+	if err := os.WriteFile(file, []byte("[cron]\nenable = true\n[[cron.jobs]]\nname = \"report\"\nspec = \"@daily\"\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-changed:
+	case <-time.After(3 * time.Second):
+		t.Fatal("config change not detected")
+	}
+	locker := loader.RLocker()
+	locker.Lock()
+	if !conf.Cron.Enable || len(conf.Cron.Jobs) != 1 || conf.Cron.Jobs[0].Name != "report" {
+		t.Errorf("config should be reloaded but get %+v", conf.Cron)
+	}
+	locker.Unlock()
+
+	// 文件中删除的配置项恢复为零值
+	if err := os.WriteFile(file, []byte("[cron]\nenable = true\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// 同一次写入可能触发多次事件，等待直到重新加载后的值生效
+	deadline := time.After(3 * time.Second)
+	for {
+		locker.Lock()
+		jobs := len(conf.Cron.Jobs)
+		locker.Unlock()
+		if jobs == 0 {
+			break
+		}
+		select {
+		case <-changed:
+		case <-deadline:
+			t.Fatalf("removed keys should be reset but get %d jobs", jobs)
+		}
+	}
+}
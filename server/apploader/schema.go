@@ -0,0 +1,124 @@
+package apploader
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// schemaDraft JSON Schema 规范版本
+const schemaDraft = "http://json-schema.org/draft-07/schema#"
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// JSONSchema 根据配置结构体生成JSON Schema，属性名称取自 mapstructure 标签，与viper解析规则一致
+func JSONSchema(config interface{}) ([]byte, error) {
+	t := reflect.TypeOf(config)
+	if t == nil {
+		return nil, fmt.Errorf("config is nil")
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("config must be a struct, got %s", t.Kind())
+	}
+
+	schema := typeSchema(t)
+	schema["$schema"] = schemaDraft
+	schema["title"] = t.Name()
+
+	return json.MarshalIndent(schema, "", "  ")
+}
+
+// WriteJSONSchema 生成JSON Schema并写入文件，供编辑器校验配置文件
+func WriteJSONSchema(path string, config interface{}) error {
+	content, err := JSONSchema(config)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(content, '\n'), 0644)
+}
+
+// typeSchema 递归生成类型对应的schema
+func typeSchema(t reflect.Type) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	// 时间间隔既可以写成 "30s" 也可以写成整数
+	if t == durationType {
+		return map[string]interface{}{"type": []string{"string", "integer"}}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": typeSchema(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": typeSchema(t.Elem())}
+	case reflect.Struct:
+		properties := make(map[string]interface{})
+		structProperties(t, properties)
+		return map[string]interface{}{
+			"type":                 "object",
+			"properties":           properties,
+			"additionalProperties": false,
+		}
+	}
+	// interface等无法确定类型，不做限制
+	return map[string]interface{}{}
+}
+
+// structProperties 解析结构体字段，squash 嵌入字段平铺到上一级
+func structProperties(t reflect.Type, properties map[string]interface{}) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, squash := fieldName(field)
+		if name == "-" {
+			continue
+		}
+
+		if squash {
+			ft := field.Type
+			for ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				structProperties(ft, properties)
+				continue
+			}
+		}
+		properties[name] = typeSchema(field.Type)
+	}
+}
+
+// fieldName 获取字段在配置文件中的名称，未设置 mapstructure 标签时使用字段名
+func fieldName(field reflect.StructField) (name string, squash bool) {
+	tag := field.Tag.Get("mapstructure")
+	parts := strings.Split(tag, ",")
+	for _, opt := range parts[1:] {
+		if opt == "squash" {
+			squash = true
+		}
+	}
+	if name = parts[0]; name == "" {
+		name = field.Name
+	}
+	return
+}
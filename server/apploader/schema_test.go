@@ -0,0 +1,93 @@
+package apploader
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/Domingor/go-blackbox/server/datasource"
+)
+
+func TestJSONSchema(t *testing.T) {
+	content, err := JSONSchema(&Configuration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var schema struct {
+		Properties map[string]struct {
+			Properties map[string]struct {
+				Type interface{} `json:"type"`
+			} `json:"properties"`
+		} `json:"properties"`
+	}
+	if err = json.Unmarshal(content, &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, section := range []string{"web", "db", "redis", "rabbitmq", "mongodb", "logConfig", "email", "token", "cron"} {
+		if _, ok := schema.Properties[section]; !ok {
+			t.Errorf("schema missing section %s", section)
+		}
+	}
+	if _, ok := schema.Properties["db"].Properties["user"]; !ok {
+		t.Error("db section should use mapstructure key user")
+	}
+	if _, ok := schema.Properties["token"].Properties["accessExpire"].Type.([]interface{}); !ok {
+		t.Error("duration field should accept string or integer")
+	}
+}
+
+func TestLoadConfigToml(t *testing.T) {
+	var conf Configuration
+	if err := NewLoader().SetConfigFileSearcher("config", "../../").LoadToStruct(&conf); err != nil {
+		t.Fatal(err)
+	}
+	if conf.Db.UserName == "" || conf.Redis.Addr == "" || conf.RabbitMq.QuName == "" || conf.LogConf.Level == "" {
+		t.Errorf("config.toml not fully mapped: %+v", conf)
+	}
+	if conf.Token.AccessExpire != 30*time.Minute {
+		t.Errorf("token accessExpire want 30m but get %s", conf.Token.AccessExpire)
+	}
+}
+
+func TestConfigSchemaFileUpToDate(t *testing.T) {
+	want, err := JSONSchema(&Configuration{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile("../../config.schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(bytes.TrimSpace(got), want) {
+		t.Error("config.schema.json is outdated, regenerate it with apploader.WriteJSONSchema")
+	}
+}
+
+func TestDbConfigs(t *testing.T) {
+	dir := t.TempDir()
+	content := "[dbs.report]\ndriver = \"sqlite\"\ndbName = \"report.db\"\n\n[dbs.audit]\ndriver = \"sqlite\"\ndbName = \"audit.db\"\naliasName = \"audit\"\n"
+	if err := os.WriteFile(filepath.Join(dir, "app.toml"), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	var conf Configuration
+	if err := NewLoader().SetConfigFileSearcher("app", dir).LoadToStruct(&conf); err != nil {
+		t.Fatal(err)
+	}
+	configs, err := conf.DbConfigs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(configs) != 2 || configs[0].Alias() != "audit" || configs[1].Alias() != "report" || configs[1].DbName != "report.db" {
+		t.Fatalf("alias should be taken from key but get %+v", configs)
+	}
+
+	conf.Dbs["audit"] = datasource.DataSourceConfig{AliasName: "report"}
+	if _, err = conf.DbConfigs(); err == nil {
+		t.Error("alias not matching key should fail")
+	}
+}
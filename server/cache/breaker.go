@@ -0,0 +1,121 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/Domingor/go-blackbox/server/zaplog"
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrCircuitOpen redis连续失败后熔断，请求不再访问redis直接返回该错误
+var ErrCircuitOpen = errors.New("cache: circuit breaker is open")
+
+// 熔断状态
+const (
+	stateClosed   = iota // 正常访问redis
+	stateOpen            // 熔断中，快速失败
+	stateHalfOpen        // 冷却结束，放行一个探测请求
+)
+
+// breaker 熔断器：连续 threshold 次连接类错误后熔断，cooldown 后放行一个探测请求，成功则恢复
+type breaker struct {
+	mu        sync.Mutex
+	state     int
+	failures  int
+	openedAt  time.Time
+	threshold int
+	cooldown  time.Duration
+}
+
+// newBreaker 创建熔断器，关闭熔断时返回nil
+func newBreaker(config BreakerConfig) *breaker {
+	if config.Disable {
+		return nil
+	}
+	config = config.withDefaults()
+	return &breaker{threshold: config.Threshold, cooldown: config.Cooldown}
+}
+
+// allow 判断本次请求能否访问redis
+func (b *breaker) allow() error {
+	if b == nil {
+		return nil
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case stateOpen:
+		if timeNow().Sub(b.openedAt) < b.cooldown {
+			return ErrCircuitOpen
+		}
+		b.state = stateHalfOpen
+		return nil
+	case stateHalfOpen:
+		// 探测请求未返回前其他请求继续快速失败
+		return ErrCircuitOpen
+	}
+	return nil
+}
+
+// record 记录请求结果，只有连接类错误计入失败次数
+func (b *breaker) record(err error) {
+	if b == nil {
+		return
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if !isUnavailable(err) {
+		if b.state != stateClosed {
+			zaplog.SugaredLogger.Infof("redis recovered, circuit breaker closed")
+		}
+		b.state, b.failures = stateClosed, 0
+		return
+	}
+
+	b.failures++
+	if b.state == stateHalfOpen || (b.state == stateClosed && b.failures >= b.threshold) {
+		if b.state == stateClosed {
+			zaplog.SugaredLogger.Warnf("redis unavailable after %d failures, circuit breaker open: %s", b.failures, err)
+		}
+		b.state, b.openedAt = stateOpen, timeNow()
+	}
+}
+
+// isUnavailable 是否为redis不可用导致的错误：网络错误、连接断开、超时；key不存在、命令错误、编解码错误不计入失败
+func isUnavailable(err error) bool {
+	if err == nil {
+		return false
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, context.DeadlineExceeded) || errors.Is(err, redis.ErrClosed)
+}
+
+// allow 熔断检查
+func (rc *RedisCache) allow() error {
+	return rc.breaker.allow()
+}
+
+// record 记录请求结果，在方法中以 defer rc.record(&err) 调用
+func (rc *RedisCache) record(err *error) {
+	rc.breaker.record(*err)
+}
+
+// getLocal 熔断时从本地缓存读取，本地缓存未开启或未命中时返回cause
+func (rc *RedisCache) getLocal(key string, value interface{}, cause error) error {
+	if rc.local == nil {
+		return cause
+	}
+	b, ok := rc.local.Get(key)
+	if !ok {
+		return cause
+	}
+	return rc.proxy.Unmarshal(b, value)
+}
@@ -0,0 +1,104 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestInitRetry(t *testing.T) {
+	ctx := context.Background()
+	t.Cleanup(func() { cacher = nil })
+
+	server := miniredis.RunT(t)
+	addr := server.Addr()
+	server.Close()
+
+	config := &RedisConfig{
+		Addr:       addr,
+		LocalCache: LocalCacheConfig{Disable: true},
+		Retry:      RetryConfig{Attempts: 2, Backoff: 10 * time.Millisecond},
+	}
+	if r, err := Init(ctx, nil); err == nil || r != nil {
+		t.Fatalf("init with nil config should fail but get %v %v", r, err)
+	}
+	if r, err := Init(ctx, config); err == nil || r != nil {
+		t.Fatalf("init should fail when redis is down but get %v %v", r, err)
+	}
+
+	// 失败后可以重新初始化，重试期间redis恢复
+	config.Retry = RetryConfig{Attempts: 10, Backoff: 20 * time.Millisecond}
+	time.AfterFunc(50*time.Millisecond, func() { _ = server.StartAddr(addr) })
+	r, err := Init(ctx, config)
+	if err != nil {
+		t.Fatalf("init should succeed after redis recovers but get %v", err)
+	}
+	if again, _ := Init(ctx, config); again != r {
+		t.Error("init should return the same instance once succeeded")
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	server, client := newMiniRedis(t)
+	rc := newRedisCache(ctx, client, LocalCacheConfig{})
+	rc.breaker = newBreaker(BreakerConfig{Threshold: 2, Cooldown: time.Second})
+
+	if err := rc.Set(ctx, "k", "v"); err != nil {
+		t.Fatal(err)
+	}
+	addr := server.Addr()
+	server.Close()
+
+	var v string
+	for i := 0; i < 2; i++ {
+		if err := rc.Get(ctx, "other", &v); err == nil || errors.Is(err, ErrCircuitOpen) {
+			t.Fatalf("request %d should reach redis and fail but get %v", i, err)
+		}
+	}
+	if err := rc.Get(ctx, "other", &v); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("breaker should be open but get %v", err)
+	}
+	if err := rc.Set(ctx, "other", "v"); !errors.Is(err, ErrCircuitOpen) {
+		t.Errorf("write should fail fast but get %v", err)
+	}
+	// 熔断时读请求降级到本地缓存
+	if err := rc.Get(ctx, "k", &v); err != nil || v != "v" {
+		t.Errorf("get should fall back to local cache but get %s %v", v, err)
+	}
+
+	if err := server.StartAddr(addr); err != nil {
+		t.Fatal(err)
+	}
+	// 连接池重新拨号前探测可能失败，每次跳过冷却时间再探测
+	var err error
+	for deadline := time.Now().Add(3 * time.Second); time.Now().Before(deadline); time.Sleep(100 * time.Millisecond) {
+		atomic.AddInt64(&clockOffset, int64(2*time.Second))
+		if err = rc.Get(ctx, "other", &v); errors.Is(err, ErrCacheMiss) {
+			break
+		}
+	}
+	if !errors.Is(err, ErrCacheMiss) {
+		t.Errorf("probe request should reach redis but get %v", err)
+	}
+	if err := rc.Set(ctx, "other", "v"); err != nil {
+		t.Errorf("breaker should be closed after recovery but get %v", err)
+	}
+}
+
+func TestIsUnavailable(t *testing.T) {
+	for _, err := range []error{nil, ErrCacheMiss, errors.New("WRONGTYPE"), context.Canceled} {
+		if isUnavailable(err) {
+			t.Errorf("%v should not count as failure", err)
+		}
+	}
+	if !isUnavailable(context.DeadlineExceeded) {
+		t.Error("timeout should count as failure")
+	}
+}
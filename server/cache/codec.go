@@ -0,0 +1,196 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/klauspost/compress/s2"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// 编码格式
+const (
+	CodecMsgpack = "msgpack" // 默认，与 go-redis/cache 存储格式兼容
+	CodecJSON    = "json"    // 便于其他语言读取、redis-cli 排查
+)
+
+// DefaultCompressThreshold 编码后超过该字节数时使用s2压缩
+const DefaultCompressThreshold = 64
+
+// 压缩标识，追加在编码结果末尾，与 go-redis/cache 保持一致
+const (
+	noCompression = 0x0
+	s2Compression = 0x1
+)
+
+// Codec 缓存值编解码，[]byte 和 string 原样存储，其他类型按格式编码后按阈值压缩
+type Codec struct {
+	name      string
+	marshal   func(v interface{}) ([]byte, error)
+	unmarshal func(b []byte, v interface{}) error
+	threshold int // 压缩阈值，小于0时不压缩
+}
+
+// NewCodec 创建编解码器，name为空时使用msgpack；compressThreshold为0时使用默认阈值，小于0时关闭压缩
+func NewCodec(name string, compressThreshold int) (*Codec, error) {
+	c := &Codec{name: name, threshold: compressThreshold}
+	switch name {
+	case CodecMsgpack, "":
+		c.name, c.marshal, c.unmarshal = CodecMsgpack, msgpack.Marshal, msgpack.Unmarshal
+	case CodecJSON:
+		c.marshal, c.unmarshal = json.Marshal, json.Unmarshal
+	default:
+		return nil, fmt.Errorf("unknown cache codec %s", name)
+	}
+	if c.threshold == 0 {
+		c.threshold = DefaultCompressThreshold
+	}
+	return c, nil
+}
+
+// Name 编码格式名称
+func (c *Codec) Name() string {
+	return c.name
+}
+
+// Marshal 编码并按阈值压缩
+func (c *Codec) Marshal(value interface{}) ([]byte, error) {
+	switch value := value.(type) {
+	case nil:
+		return nil, nil
+	case []byte:
+		return value, nil
+	case string:
+		return []byte(value), nil
+	}
+
+	data, err := c.marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	if c.threshold < 0 || len(data) < c.threshold {
+		return append(data, noCompression), nil
+	}
+	return append(s2.Encode(nil, data), s2Compression), nil
+}
+
+// Unmarshal 解压并解码
+func (c *Codec) Unmarshal(b []byte, value interface{}) error {
+	if len(b) == 0 {
+		return nil
+	}
+
+	switch value := value.(type) {
+	case nil:
+		return nil
+	case *[]byte:
+		*value = append([]byte(nil), b...)
+		return nil
+	case *string:
+		*value = string(b)
+		return nil
+	}
+
+	switch flag := b[len(b)-1]; flag {
+	case noCompression:
+		b = b[:len(b)-1]
+	case s2Compression:
+		var err error
+		if b, err = s2.Decode(nil, b[:len(b)-1]); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("unknown compression method: %x", flag)
+	}
+	return c.unmarshal(b, value)
+}
+
+// keyspace key命名空间前缀，隔离共用同一个redis的多个应用；版本号变更后旧key全部失效
+type keyspace string
+
+// newKeyspace 生成 namespace:vVersion: 形式的前缀，均为空时不加前缀
+func newKeyspace(namespace, version string) keyspace {
+	var parts []string
+	if namespace != "" {
+		parts = append(parts, namespace)
+	}
+	if version != "" {
+		parts = append(parts, "v"+strings.TrimPrefix(version, "v"))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return keyspace(strings.Join(parts, ":") + ":")
+}
+
+// key 添加前缀
+func (ks keyspace) key(key string) string {
+	return string(ks) + key
+}
+
+// keys 批量添加前缀
+func (ks keyspace) keys(keys []string) []string {
+	if ks == "" {
+		return keys
+	}
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = ks.key(key)
+	}
+	return prefixed
+}
+
+// pattern 为SCAN匹配规则添加前缀，前缀中的glob特殊字符需要转义
+func (ks keyspace) pattern(match string) string {
+	if match == "" {
+		match = "*"
+	}
+	var sb strings.Builder
+	for _, c := range string(ks) {
+		if strings.ContainsRune(`*?[]\`, c) {
+			sb.WriteByte('\\')
+		}
+		sb.WriteRune(c)
+	}
+	return sb.String() + match
+}
+
+// trim 去掉前缀，Scan 返回给调用方的key不带前缀
+func (ks keyspace) trim(key string) string {
+	return strings.TrimPrefix(key, string(ks))
+}
+
+// Option 缓存实例可选参数
+type Option func(*options)
+
+type options struct {
+	codec    *Codec
+	keyspace keyspace
+}
+
+// WithCodec 设置编解码器
+func WithCodec(codec *Codec) Option {
+	return func(o *options) {
+		o.codec = codec
+	}
+}
+
+// WithNamespace 设置key命名空间和版本号
+func WithNamespace(namespace, version string) Option {
+	return func(o *options) {
+		o.keyspace = newKeyspace(namespace, version)
+	}
+}
+
+// newOptions 合并可选参数，默认msgpack编码、不加前缀
+func newOptions(opts []Option) *options {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.codec == nil {
+		o.codec, _ = NewCodec(CodecMsgpack, 0)
+	}
+	return o
+}
@@ -0,0 +1,144 @@
+package cache
+
+import (
+	"bytes"
+	"context"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-redis/cache/v9"
+)
+
+func TestCodec(t *testing.T) {
+	long := user{ID: 1, Name: strings.Repeat("Homelander", 20)}
+	for _, name := range []string{CodecMsgpack, CodecJSON} {
+		for _, threshold := range []int{0, -1} {
+			codec, err := NewCodec(name, threshold)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, v := range []user{{ID: 2, Name: "A-Train"}, long} {
+				b, err := codec.Marshal(v)
+				if err != nil {
+					t.Fatal(err)
+				}
+				var got user
+				if err = codec.Unmarshal(b, &got); err != nil || got != v {
+					t.Errorf("%s threshold %d round trip want %v but get %v %v", name, threshold, v, got, err)
+				}
+			}
+		}
+	}
+
+	codec, _ := NewCodec(CodecJSON, 0)
+	b, _ := codec.Marshal(long)
+	if b[len(b)-1] != s2Compression {
+		t.Error("value above threshold should be compressed")
+	}
+	b, _ = codec.Marshal(user{ID: 3})
+	if !bytes.HasPrefix(b, []byte(`{"ID":3`)) || b[len(b)-1] != noCompression {
+		t.Errorf("small json value should be stored as is but get %q", b)
+	}
+	if _, err := NewCodec("gob", 0); err == nil {
+		t.Error("unknown codec should fail")
+	}
+}
+
+// 默认msgpack格式与 go-redis/cache 兼容，升级后已有缓存仍可读取
+func TestCodecCompatible(t *testing.T) {
+	codec, _ := NewCodec(CodecMsgpack, 0)
+	legacy := cache.New(&cache.Options{})
+	for _, v := range []user{{ID: 1}, {ID: 2, Name: strings.Repeat("x", 200)}} {
+		b, _ := legacy.Marshal(v)
+		var got user
+		if err := codec.Unmarshal(b, &got); err != nil || got != v {
+			t.Errorf("decode legacy value want %v but get %v %v", v, got, err)
+		}
+		b, _ = codec.Marshal(v)
+		if err := legacy.Unmarshal(b, &got); err != nil || got != v {
+			t.Errorf("legacy decode want %v but get %v %v", v, got, err)
+		}
+	}
+}
+
+func TestKeyspace(t *testing.T) {
+	cases := []struct {
+		namespace, version string
+		want               keyspace
+	}{
+		{"", "", ""},
+		{"shop", "", "shop:"},
+		{"shop", "3", "shop:v3:"},
+		{"shop", "v3", "shop:v3:"},
+		{"", "2", "v2:"},
+	}
+	for _, c := range cases {
+		if got := newKeyspace(c.namespace, c.version); got != c.want {
+			t.Errorf("namespace %q version %q want %q but get %q", c.namespace, c.version, c.want, got)
+		}
+	}
+	if got := keyspace("a*:").pattern("user:*"); got != `a\*:user:*` {
+		t.Errorf("prefix should be escaped but get %s", got)
+	}
+}
+
+func TestNamespace(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	server, client := newMiniRedis(t)
+	codec, _ := NewCodec(CodecJSON, 0)
+	v1 := newRedisCache(ctx, client, LocalCacheConfig{Disable: true}, WithCodec(codec), WithNamespace("shop", "1"))
+	v2 := newRedisCache(ctx, client, LocalCacheConfig{Disable: true}, WithCodec(codec), WithNamespace("shop", "2"))
+	other := newRedisCache(ctx, client, LocalCacheConfig{Disable: true}, WithNamespace("blog", ""))
+
+	_ = v1.Set(ctx, "user:1", user{ID: 1})
+	_ = other.Set(ctx, "user:1", user{ID: 9})
+	if !server.Exists("shop:v1:user:1") || !server.Exists("blog:user:1") {
+		t.Fatalf("keys should be prefixed but get %v", server.Keys())
+	}
+
+	var u user
+	if err := v1.Get(ctx, "user:1", &u); err != nil || u.ID != 1 {
+		t.Errorf("want id 1 but get %v %v", u, err)
+	}
+	// 版本号变更后旧缓存不可见
+	if err := v2.Get(ctx, "user:1", &u); err != ErrCacheMiss {
+		t.Errorf("new version should miss but get %v", err)
+	}
+
+	keys, _ := ScanKeys(ctx, v1, "*")
+	sort.Strings(keys)
+	if len(keys) != 1 || keys[0] != "user:1" {
+		t.Errorf("scan should only return own keys without prefix but get %v", keys)
+	}
+	if n, _ := other.Delete(ctx, "user:1"); n != 1 || !v1.IsExists(ctx, "user:1") {
+		t.Error("delete should only affect own namespace")
+	}
+}
+
+func TestRedisCacheNamespace(t *testing.T) {
+	testRediser(t, func(t *testing.T) backend {
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+
+		server, client := newMiniRedis(t)
+		codec, _ := NewCodec(CodecJSON, 16)
+		rc := newRedisCache(ctx, client, LocalCacheConfig{Disable: true}, WithCodec(codec), WithNamespace("app", "1"))
+		return backend{cache: rc, advance: func(d time.Duration) {
+			server.FastForward(d)
+		}}
+	})
+}
+
+func TestMemoryCacheNamespace(t *testing.T) {
+	testRediser(t, func(t *testing.T) backend {
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+
+		codec, _ := NewCodec(CodecJSON, -1)
+		return backend{cache: NewMemoryCache(ctx, WithCodec(codec), WithNamespace("app", "1"))}
+	})
+}
@@ -0,0 +1,138 @@
+package cache
+
+import "time"
+
+// 缓存驱动
+const (
+	DriverRedis  = "redis"  // redis缓存，默认
+	DriverMemory = "memory" // 进程内存缓存，用于本地开发、单元测试
+)
+
+// redis部署模式
+const (
+	ModeStandalone = "standalone" // 单节点，默认
+	ModeSentinel   = "sentinel"   // 哨兵主从切换
+	ModeCluster    = "cluster"    // 集群
+)
+
+// 本地缓存默认配置
+const (
+	DefaultLocalSize           = 1000
+	DefaultLocalTTL            = time.Minute
+	DefaultInvalidationChannel = "go-blackbox:cache:invalidate"
+)
+
+// 连接重试、熔断默认配置
+const (
+	DefaultRetryAttempts    = 5
+	DefaultRetryBackoff     = 200 * time.Millisecond
+	DefaultRetryMaxBackoff  = 5 * time.Second
+	DefaultBreakerThreshold = 5
+	DefaultBreakerCooldown  = 10 * time.Second
+)
+
+// RedisConfig redis配置文件对象
+type RedisConfig struct {
+	Driver            string           `mapstructure:"driver" json:"driver" yaml:"driver"`                                  // 缓存驱动 redis/memory，默认redis
+	Mode              string           `mapstructure:"mode" json:"mode" yaml:"mode"`                                        // 部署模式 standalone/sentinel/cluster，默认standalone
+	Addr              string           `mapstructure:"addr" json:"addr" yaml:"addr"`                                        // 单节点连接地址 host:port
+	Addrs             []string         `mapstructure:"addrs" json:"addrs" yaml:"addrs"`                                     // 哨兵或集群节点地址列表
+	MasterName        string           `mapstructure:"masterName" json:"masterName" yaml:"masterName"`                      // 哨兵模式主节点名称
+	Password          string           `mapstructure:"password" json:"password" yaml:"password"`                            // 连接密码
+	SentinelPassword  string           `mapstructure:"sentinelPassword" json:"sentinelPassword" yaml:"sentinelPassword"`    // 哨兵节点密码
+	Db                int              `mapstructure:"db" json:"db" yaml:"db"`                                              // 数据库序号，集群模式不支持
+	PoolSize          int              `mapstructure:"poolSize" json:"poolSize" yaml:"poolSize"`                            // 连接池大小
+	Codec             string           `mapstructure:"codec" json:"codec" yaml:"codec"`                                     // 编码格式 msgpack/json，默认msgpack
+	CompressThreshold int              `mapstructure:"compressThreshold" json:"compressThreshold" yaml:"compressThreshold"` // 超过该字节数时压缩，默认64，-1关闭压缩
+	Namespace         string           `mapstructure:"namespace" json:"namespace" yaml:"namespace"`                         // key命名空间，多个应用共用redis时隔离key
+	Version           string           `mapstructure:"version" json:"version" yaml:"version"`                               // key版本号，修改后旧缓存全部失效
+	LocalCache        LocalCacheConfig `mapstructure:"localCache" json:"localCache" yaml:"localCache"`                      // 进程内本地缓存
+	Retry             RetryConfig      `mapstructure:"retry" json:"retry" yaml:"retry"`                                     // 首次连接重试
+	Breaker           BreakerConfig    `mapstructure:"breaker" json:"breaker" yaml:"breaker"`                               // 运行期熔断
+}
+
+// RetryConfig 首次连接失败时按指数退避重试
+type RetryConfig struct {
+	Attempts   int           `mapstructure:"attempts" json:"attempts" yaml:"attempts"`       // 最大尝试次数，默认5
+	Backoff    time.Duration `mapstructure:"backoff" json:"backoff" yaml:"backoff"`          // 首次重试间隔，之后每次翻倍，默认200ms
+	MaxBackoff time.Duration `mapstructure:"maxBackoff" json:"maxBackoff" yaml:"maxBackoff"` // 最大重试间隔，默认5s
+}
+
+// BreakerConfig 熔断配置，连续失败达到阈值后在冷却时间内快速失败，读请求降级到本地缓存
+type BreakerConfig struct {
+	Disable   bool          `mapstructure:"disable" json:"disable" yaml:"disable"`       // 关闭熔断
+	Threshold int           `mapstructure:"threshold" json:"threshold" yaml:"threshold"` // 连续失败次数阈值，默认5
+	Cooldown  time.Duration `mapstructure:"cooldown" json:"cooldown" yaml:"cooldown"`    // 熔断后多久放行一次探测请求，默认10s
+}
+
+// LocalCacheConfig 本地缓存配置，写入、删除时通过redis发布订阅通知其他实例清除本地缓存
+type LocalCacheConfig struct {
+	Disable             bool          `mapstructure:"disable" json:"disable" yaml:"disable"`                                     // 关闭本地缓存
+	Size                int           `mapstructure:"size" json:"size" yaml:"size"`                                              // 最大缓存数量，默认1000
+	TTL                 time.Duration `mapstructure:"ttl" json:"ttl" yaml:"ttl"`                                                 // 本地缓存有效期，默认1分钟
+	InvalidationChannel string        `mapstructure:"invalidationChannel" json:"invalidationChannel" yaml:"invalidationChannel"` // 失效通知频道
+}
+
+// Options 转换为redis连接参数，addr 与 addrs 合并为节点列表
+func (rc *RedisConfig) Options() RedisOptions {
+	addrs := rc.Addrs
+	if rc.Addr != "" {
+		addrs = append([]string{rc.Addr}, addrs...)
+	}
+	return RedisOptions{
+		Addrs:            addrs,
+		MasterName:       rc.MasterName,
+		Password:         rc.Password,
+		SentinelPassword: rc.SentinelPassword,
+		DB:               rc.Db,
+		PoolSize:         rc.PoolSize,
+	}
+}
+
+// cacheOptions 转换为编解码、命名空间参数
+func (rc *RedisConfig) cacheOptions() ([]Option, error) {
+	codec, err := NewCodec(rc.Codec, rc.CompressThreshold)
+	if err != nil {
+		return nil, err
+	}
+	return []Option{WithCodec(codec), WithNamespace(rc.Namespace, rc.Version)}, nil
+}
+
+// withDefaults 填充本地缓存默认值
+func (lc LocalCacheConfig) withDefaults() LocalCacheConfig {
+	if lc.Size <= 0 {
+		lc.Size = DefaultLocalSize
+	}
+	if lc.TTL <= 0 {
+		lc.TTL = DefaultLocalTTL
+	}
+	if lc.InvalidationChannel == "" {
+		lc.InvalidationChannel = DefaultInvalidationChannel
+	}
+	return lc
+}
+
+// withDefaults 填充重试默认值
+func (rc RetryConfig) withDefaults() RetryConfig {
+	if rc.Attempts <= 0 {
+		rc.Attempts = DefaultRetryAttempts
+	}
+	if rc.Backoff <= 0 {
+		rc.Backoff = DefaultRetryBackoff
+	}
+	if rc.MaxBackoff <= 0 {
+		rc.MaxBackoff = DefaultRetryMaxBackoff
+	}
+	return rc
+}
+
+// withDefaults 填充熔断默认值
+func (bc BreakerConfig) withDefaults() BreakerConfig {
+	if bc.Threshold <= 0 {
+		bc.Threshold = DefaultBreakerThreshold
+	}
+	if bc.Cooldown <= 0 {
+		bc.Cooldown = DefaultBreakerCooldown
+	}
+	return bc
+}
@@ -0,0 +1,296 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Domingor/go-blackbox/server/zaplog"
+	"go.uber.org/zap"
+)
+
+// clockOffset 模拟时间流逝的偏移量
+var clockOffset int64
+
+func TestMain(m *testing.M) {
+	zaplog.Logger = zap.NewNop()
+	zaplog.SugaredLogger = zaplog.Logger.Sugar()
+	timeNow = func() time.Time {
+		return time.Now().Add(time.Duration(atomic.LoadInt64(&clockOffset)))
+	}
+	os.Exit(m.Run())
+}
+
+// backend 待测试的 Rediser 实现，advance 用于模拟存储端的时间流逝
+type backend struct {
+	cache   Rediser
+	advance func(d time.Duration)
+}
+
+// skip 模拟时间流逝，同时推进存储端和软过期判断使用的时钟
+func (b backend) skip(d time.Duration) {
+	atomic.AddInt64(&clockOffset, int64(d))
+	if b.advance != nil {
+		b.advance(d)
+	}
+}
+
+type user struct {
+	ID   int
+	Name string
+}
+
+// testRediser Rediser 实现需要满足的公共行为，每个实现都要通过
+func testRediser(t *testing.T, newBackend func(t *testing.T) backend) {
+	ctx := context.Background()
+
+	t.Run("get set", func(t *testing.T) {
+		b := newBackend(t)
+		var u user
+		if err := b.cache.Get(ctx, "user:1", &u); !errors.Is(err, ErrCacheMiss) {
+			t.Errorf("get missing key want ErrCacheMiss but get %v", err)
+		}
+		if err := b.cache.Set(ctx, "user:1", user{ID: 1, Name: "Homelander"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := b.cache.Get(ctx, "user:1", &u); err != nil || u.Name != "Homelander" {
+			t.Errorf("get want Homelander but get %v %v", u, err)
+		}
+		if !b.cache.IsExists(ctx, "user:1") {
+			t.Error("key should exist")
+		}
+		got, err := GetAs[user](ctx, b.cache, "user:1")
+		if err != nil || got.ID != 1 {
+			t.Errorf("GetAs want id 1 but get %v %v", got, err)
+		}
+	})
+
+	t.Run("ttl expire", func(t *testing.T) {
+		b := newBackend(t)
+		if err := b.cache.SetTtl(ctx, "k", "v", 10*time.Second); err != nil {
+			t.Fatal(err)
+		}
+		if ttl, err := b.cache.TTL(ctx, "k"); err != nil || ttl <= 0 || ttl > 10*time.Second {
+			t.Errorf("ttl want (0,10s] but get %s %v", ttl, err)
+		}
+		b.skip(11 * time.Second)
+		if b.cache.IsExists(ctx, "k") {
+			t.Error("key should be expired")
+		}
+		if _, err := b.cache.TTL(ctx, "k"); !errors.Is(err, ErrCacheMiss) {
+			t.Errorf("ttl of missing key want ErrCacheMiss but get %v", err)
+		}
+
+		_ = b.cache.SetTtl(ctx, "k", "v", 10*time.Second)
+		if ok, err := b.cache.Expire(ctx, "k", 30*time.Second); !ok || err != nil {
+			t.Errorf("expire want true but get %v %v", ok, err)
+		}
+		b.skip(20 * time.Second)
+		if !b.cache.IsExists(ctx, "k") {
+			t.Error("expire should extend ttl")
+		}
+		if ok, _ := b.cache.Expire(ctx, "missing", time.Second); ok {
+			t.Error("expire missing key want false")
+		}
+	})
+
+	t.Run("setnx delete", func(t *testing.T) {
+		b := newBackend(t)
+		if ok, err := b.cache.SetNX(ctx, "nx", 1, time.Minute); !ok || err != nil {
+			t.Errorf("first setnx want true but get %v %v", ok, err)
+		}
+		if ok, _ := b.cache.SetNX(ctx, "nx", 2, time.Minute); ok {
+			t.Error("second setnx want false")
+		}
+		if v, _ := GetAs[int](ctx, b.cache, "nx"); v != 1 {
+			t.Errorf("setnx should keep first value but get %d", v)
+		}
+		_ = b.cache.Set(ctx, "other", 1)
+		if n, err := b.cache.Delete(ctx, "nx", "other", "missing"); n != 2 || err != nil {
+			t.Errorf("delete want 2 but get %d %v", n, err)
+		}
+		if b.cache.IsExists(ctx, "nx") {
+			t.Error("deleted key should not exist")
+		}
+	})
+
+	t.Run("counter", func(t *testing.T) {
+		b := newBackend(t)
+		if n, _ := b.cache.Incr(ctx, "c"); n != 1 {
+			t.Errorf("incr want 1 but get %d", n)
+		}
+		if n, _ := b.cache.IncrBy(ctx, "c", 10); n != 11 {
+			t.Errorf("incrBy want 11 but get %d", n)
+		}
+		if n, _ := b.cache.Decr(ctx, "c"); n != 10 {
+			t.Errorf("decr want 10 but get %d", n)
+		}
+		if n, _ := b.cache.DecrBy(ctx, "c", 4); n != 6 {
+			t.Errorf("decrBy want 6 but get %d", n)
+		}
+		if ttl, err := b.cache.TTL(ctx, "c"); ttl != NoExpiration || err != nil {
+			t.Errorf("counter ttl want NoExpiration but get %s %v", ttl, err)
+		}
+		_ = b.cache.Set(ctx, "s", user{Name: "x"})
+		if _, err := b.cache.Incr(ctx, "s"); err == nil {
+			t.Error("incr on non integer value should fail")
+		}
+	})
+
+	t.Run("mget mset", func(t *testing.T) {
+		b := newBackend(t)
+		err := b.cache.MSet(ctx, map[string]interface{}{
+			"m:1": user{ID: 1},
+			"m:2": user{ID: 2},
+		}, time.Minute)
+		if err != nil {
+			t.Fatal(err)
+		}
+		values, err := MGetAs[user](ctx, b.cache, "m:1", "m:2", "m:3")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(values) != 2 || values["m:2"].ID != 2 {
+			t.Errorf("mget want 2 values but get %v", values)
+		}
+		raw, _ := b.cache.MGet(ctx, "m:3", "m:1")
+		if len(raw) != 2 || raw[0] != nil || raw[1] == nil {
+			t.Errorf("mget missing key should be nil but get %v", raw)
+		}
+	})
+
+	t.Run("scan", func(t *testing.T) {
+		b := newBackend(t)
+		for _, key := range []string{"order:1", "order:2", "order:10", "user:1"} {
+			_ = b.cache.Set(ctx, key, 1)
+		}
+		keys, err := ScanKeys(ctx, b.cache, "order:*")
+		if err != nil {
+			t.Fatal(err)
+		}
+		sort.Strings(keys)
+		if len(keys) != 3 || keys[0] != "order:1" {
+			t.Errorf("scan order:* want 3 keys but get %v", keys)
+		}
+		keys, _ = ScanKeys(ctx, b.cache, "order:?")
+		if len(keys) != 2 {
+			t.Errorf("scan order:? want 2 keys but get %v", keys)
+		}
+		stop := errors.New("stop")
+		if err = b.cache.Scan(ctx, "*", 10, func(string) error { return stop }); !errors.Is(err, stop) {
+			t.Errorf("scan should return callback error but get %v", err)
+		}
+	})
+
+	t.Run("tags", func(t *testing.T) {
+		b := newBackend(t)
+		_ = b.cache.SetWithTags(ctx, "user:1", user{ID: 1}, time.Minute, "user:1")
+		_ = b.cache.SetWithTags(ctx, "list:1", []int{1, 2}, time.Minute, "user:1", "user:2", "list")
+		_ = b.cache.SetWithTags(ctx, "list:2", []int{2}, time.Minute, "user:2", "list")
+		var u user
+		_ = b.cache.GetOrLoad(ctx, "load:1", time.Minute, func(ctx context.Context) (interface{}, error) {
+			return user{ID: 1}, nil
+		}, &u, WithTags("user:1"))
+
+		if n, err := b.cache.InvalidateTags(ctx, "user:1"); n != 3 || err != nil {
+			t.Errorf("invalidate user:1 want 3 but get %d %v", n, err)
+		}
+		if b.cache.IsExists(ctx, "user:1") || b.cache.IsExists(ctx, "list:1") || b.cache.IsExists(ctx, "load:1") {
+			t.Error("tagged keys should be deleted")
+		}
+		if !b.cache.IsExists(ctx, "list:2") {
+			t.Error("untagged key should be kept")
+		}
+		if n, _ := b.cache.InvalidateTags(ctx, "user:1"); n != 0 {
+			t.Errorf("invalidated tag should be removed but delete %d", n)
+		}
+
+		// 成员全部过期后标签随之过期
+		_ = b.cache.SetWithTags(ctx, "short", 1, 10*time.Second, "expire")
+		b.skip(11 * time.Second)
+		_ = b.cache.Set(ctx, "short", 2)
+		if n, _ := b.cache.InvalidateTags(ctx, "expire"); n != 0 || !b.cache.IsExists(ctx, "short") {
+			t.Errorf("expired tag should not delete rewritten key but delete %d", n)
+		}
+	})
+
+	t.Run("get or load", func(t *testing.T) {
+		b := newBackend(t)
+		var calls int32
+		loader := func(ctx context.Context) (interface{}, error) {
+			atomic.AddInt32(&calls, 1)
+			time.Sleep(20 * time.Millisecond)
+			return user{ID: 7}, nil
+		}
+
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				var u user
+				if err := b.cache.GetOrLoad(ctx, "load:7", time.Minute, loader, &u); err != nil || u.ID != 7 {
+					t.Errorf("GetOrLoad want id 7 but get %v %v", u, err)
+				}
+			}()
+		}
+		wg.Wait()
+		if calls != 1 {
+			t.Errorf("loader should run once but run %d times", calls)
+		}
+
+		got, err := GetOrLoadAs(ctx, b.cache, "load:7", time.Minute, func(ctx context.Context) (user, error) {
+			return user{}, errors.New("should not load")
+		})
+		if err != nil || got.ID != 7 {
+			t.Errorf("GetOrLoadAs should hit cache but get %v %v", got, err)
+		}
+	})
+
+	t.Run("get or load negative", func(t *testing.T) {
+		b := newBackend(t)
+		var calls int32
+		loader := func(ctx context.Context) (interface{}, error) {
+			atomic.AddInt32(&calls, 1)
+			return nil, ErrNotFound
+		}
+		for i := 0; i < 3; i++ {
+			var u user
+			err := b.cache.GetOrLoad(ctx, "load:404", time.Minute, loader, &u, WithNegativeTTL(10*time.Second))
+			if !errors.Is(err, ErrNotFound) {
+				t.Errorf("want ErrNotFound but get %v", err)
+			}
+		}
+		if calls != 1 {
+			t.Errorf("negative result should be cached but loader run %d times", calls)
+		}
+	})
+
+	t.Run("get or load stale", func(t *testing.T) {
+		b := newBackend(t)
+		var version int32
+		loader := func(ctx context.Context) (interface{}, error) {
+			return int(atomic.AddInt32(&version, 1)), nil
+		}
+		var v int
+		_ = b.cache.GetOrLoad(ctx, "stale", 10*time.Second, loader, &v, WithStale(time.Minute))
+		b.skip(15 * time.Second)
+		if err := b.cache.GetOrLoad(ctx, "stale", 10*time.Second, loader, &v, WithStale(time.Minute)); err != nil || v != 1 {
+			t.Errorf("stale read want old value 1 but get %d %v", v, err)
+		}
+		// 等待后台刷新完成
+		deadline := time.Now().Add(time.Second)
+		for atomic.LoadInt32(&version) < 2 && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		time.Sleep(20 * time.Millisecond)
+		if err := b.cache.GetOrLoad(ctx, "stale", 10*time.Second, loader, &v, WithStale(time.Minute)); err != nil || v != 2 {
+			t.Errorf("refreshed read want 2 but get %d %v", v, err)
+		}
+	})
+}
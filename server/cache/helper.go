@@ -0,0 +1,38 @@
+package cache
+
+import "context"
+
+// GetAs 获取key并解码为指定类型
+func GetAs[T any](ctx context.Context, r Rediser, key string) (value T, err error) {
+	err = r.Get(ctx, key, &value)
+	return
+}
+
+// MGetAs 批量获取key并解码为指定类型，不存在的key不会出现在结果中
+func MGetAs[T any](ctx context.Context, r Rediser, keys ...string) (values map[string]T, err error) {
+	raw, err := r.MGet(ctx, keys...)
+	if err != nil {
+		return
+	}
+	values = make(map[string]T, len(raw))
+	for i, b := range raw {
+		if b == nil {
+			continue
+		}
+		var value T
+		if err = r.Unmarshal(b, &value); err != nil {
+			return nil, err
+		}
+		values[keys[i]] = value
+	}
+	return
+}
+
+// ScanKeys 收集所有匹配的key，适用于key数量可控的场景
+func ScanKeys(ctx context.Context, r Rediser, match string) (keys []string, err error) {
+	err = r.Scan(ctx, match, 0, func(key string) error {
+		keys = append(keys, key)
+		return nil
+	})
+	return
+}
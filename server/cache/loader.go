@@ -0,0 +1,139 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Domingor/go-blackbox/server/zaplog"
+	"golang.org/x/sync/singleflight"
+)
+
+// timeNow 当前时间，测试时可替换
+var timeNow = time.Now
+
+// ErrNotFound loader返回该错误表示数据不存在，配置 WithNegativeTTL 后会被缓存，避免反复穿透数据库
+var ErrNotFound = errors.New("cache: value not found")
+
+// LoaderFunc 缓存未命中时加载数据
+type LoaderFunc func(ctx context.Context) (value interface{}, err error)
+
+// LoadOption GetOrLoad 可选参数
+type LoadOption func(*loadOptions)
+
+type loadOptions struct {
+	staleTTL    time.Duration // 软过期后仍可返回旧值的时长
+	negativeTTL time.Duration // 数据不存在时的缓存时长
+	tags        []string      // 写入缓存时打上的标签
+}
+
+// WithStale 软过期后的 staleTTL 时间内直接返回旧值，同时由一个请求在后台刷新
+func WithStale(staleTTL time.Duration) LoadOption {
+	return func(o *loadOptions) {
+		o.staleTTL = staleTTL
+	}
+}
+
+// WithNegativeTTL loader返回 ErrNotFound 时缓存该结果 negativeTTL 时长
+func WithNegativeTTL(negativeTTL time.Duration) LoadOption {
+	return func(o *loadOptions) {
+		o.negativeTTL = negativeTTL
+	}
+}
+
+// WithTags 回源结果写入缓存时打上标签，数据变更时通过 InvalidateTags 清除
+func WithTags(tags ...string) LoadOption {
+	return func(o *loadOptions) {
+		o.tags = append(o.tags, tags...)
+	}
+}
+
+// loadEntry GetOrLoad 写入缓存的包装结构，记录软过期时间
+type loadEntry struct {
+	Value    []byte `msgpack:"v" json:"v"`
+	ExpireAt int64  `msgpack:"e" json:"e"` // 软过期时间 unix纳秒
+	NotFound bool   `msgpack:"n" json:"n"` // 负缓存标识
+}
+
+// decode 解码包装结构中的值
+func (e *loadEntry) decode(r Rediser, value interface{}) error {
+	if e.NotFound {
+		return ErrNotFound
+	}
+	return r.Unmarshal(e.Value, value)
+}
+
+// getOrLoad cache-aside 读取：命中直接返回，未命中时同一进程内同一个key只有一个请求回源
+func getOrLoad(ctx context.Context, r Rediser, group *singleflight.Group, key string, ttl time.Duration,
+	loader LoaderFunc, value interface{}, opts ...LoadOption) error {
+	o := &loadOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+
+	var entry loadEntry
+	if err := r.Get(ctx, key, &entry); err == nil {
+		if timeNow().UnixNano() < entry.ExpireAt {
+			return entry.decode(r, value)
+		}
+		// 允许返回旧值：后台刷新，不阻塞当前请求
+		if o.staleTTL > 0 {
+			group.DoChan(key, func() (interface{}, error) {
+				return load(context.Background(), r, key, ttl, loader, o)
+			})
+			return entry.decode(r, value)
+		}
+	} else if !errors.Is(err, ErrCacheMiss) {
+		// 缓存不可用或旧数据格式不兼容时直接回源
+		zaplog.SugaredLogger.Debugf("cache get %s failed, loading from source: %s", key, err)
+	}
+
+	v, err, _ := group.Do(key, func() (interface{}, error) {
+		return load(ctx, r, key, ttl, loader, o)
+	})
+	if err != nil {
+		return err
+	}
+	return v.(*loadEntry).decode(r, value)
+}
+
+// load 执行loader并写入缓存，写缓存失败不影响返回结果
+func load(ctx context.Context, r Rediser, key string, ttl time.Duration, loader LoaderFunc, o *loadOptions) (*loadEntry, error) {
+	result, err := loader(ctx)
+
+	entry := &loadEntry{}
+	hardTTL := ttl + o.staleTTL
+	switch {
+	case errors.Is(err, ErrNotFound):
+		if o.negativeTTL <= 0 {
+			return nil, err
+		}
+		entry.NotFound = true
+		entry.ExpireAt = timeNow().Add(o.negativeTTL).UnixNano()
+		hardTTL = o.negativeTTL
+	case err != nil:
+		return nil, err
+	default:
+		if entry.Value, err = r.Marshal(result); err != nil {
+			return nil, err
+		}
+		entry.ExpireAt = timeNow().Add(ttl).UnixNano()
+	}
+
+	if err = r.SetWithTags(ctx, key, entry, hardTTL, o.tags...); err != nil {
+		zaplog.SugaredLogger.Debugf("cache set %s failed: %s", key, err)
+	}
+	return entry, nil
+}
+
+// GetOrLoadAs 泛型版本的 GetOrLoad
+func GetOrLoadAs[T any](ctx context.Context, r Rediser, key string, ttl time.Duration,
+	loader func(ctx context.Context) (T, error), opts ...LoadOption) (value T, err error) {
+	err = r.GetOrLoad(ctx, key, ttl, func(ctx context.Context) (interface{}, error) {
+		return loader(ctx)
+	}, &value, opts...)
+	return
+}
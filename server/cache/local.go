@@ -0,0 +1,131 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"sync/atomic"
+
+	"github.com/Domingor/go-blackbox/server/zaplog"
+	"github.com/go-redis/cache/v9"
+	"github.com/redis/go-redis/v9"
+)
+
+// TierStats 单层缓存命中统计
+type TierStats struct {
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+}
+
+// Stats 各层缓存命中统计
+type Stats struct {
+	Local  TierStats `json:"local"`  // 进程内本地缓存
+	Remote TierStats `json:"remote"` // redis等远端缓存
+}
+
+// countingLocalCache 统计命中次数的本地缓存
+type countingLocalCache struct {
+	cache.LocalCache
+	hits   uint64
+	misses uint64
+}
+
+func (c *countingLocalCache) Get(key string) ([]byte, bool) {
+	b, ok := c.LocalCache.Get(key)
+	if ok {
+		atomic.AddUint64(&c.hits, 1)
+	} else {
+		atomic.AddUint64(&c.misses, 1)
+	}
+	return b, ok
+}
+
+func (c *countingLocalCache) stats() TierStats {
+	return TierStats{
+		Hits:   atomic.LoadUint64(&c.hits),
+		Misses: atomic.LoadUint64(&c.misses),
+	}
+}
+
+// invalidation 失效通知消息
+type invalidation struct {
+	Instance string   `json:"i"` // 发送通知的实例，收到自身的通知时忽略
+	Keys     []string `json:"k"`
+}
+
+// newInstanceID 生成实例唯一标识
+func newInstanceID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// invalidate 清除本地缓存，并通知其他实例清除
+func (rc *RedisCache) invalidate(ctx context.Context, keys ...string) {
+	if rc.local == nil {
+		return
+	}
+	for _, key := range keys {
+		rc.local.Del(key)
+	}
+	rc.notify(ctx, keys...)
+}
+
+// notify 通知其他实例清除本地缓存，本实例的本地缓存已是最新值
+func (rc *RedisCache) notify(ctx context.Context, keys ...string) {
+	if rc.local == nil || len(keys) == 0 {
+		return
+	}
+	payload, _ := json.Marshal(invalidation{Instance: rc.instanceID, Keys: keys})
+	if err := rc.client.Publish(ctx, rc.channel, payload).Err(); err != nil {
+		zaplog.SugaredLogger.Debugf("publish cache invalidation failed: %s", err)
+	}
+}
+
+// subscribeInvalidation 订阅失效通知，清除本实例的本地缓存，ctx结束后退出
+func (rc *RedisCache) subscribeInvalidation(ctx context.Context) {
+	sub := rc.client.Subscribe(ctx, rc.channel)
+	go func() {
+		defer sub.Close()
+
+		ch := sub.Channel()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg, ok := <-ch:
+				if !ok {
+					return
+				}
+				rc.onInvalidation(msg)
+			}
+		}
+	}()
+}
+
+func (rc *RedisCache) onInvalidation(msg *redis.Message) {
+	var inv invalidation
+	if err := json.Unmarshal([]byte(msg.Payload), &inv); err != nil {
+		zaplog.SugaredLogger.Debugf("invalid cache invalidation message: %s", err)
+		return
+	}
+	if inv.Instance == rc.instanceID {
+		return
+	}
+	for _, key := range inv.Keys {
+		rc.local.Del(key)
+	}
+}
+
+// Stats 获取各层缓存命中统计
+func (rc *RedisCache) Stats() Stats {
+	stats := Stats{}
+	if rc.local != nil {
+		stats.Local = rc.local.stats()
+	}
+	if s := rc.proxy.Stats(); s != nil {
+		stats.Remote = TierStats{Hits: s.Hits, Misses: s.Misses}
+	}
+	return stats
+}
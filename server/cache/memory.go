@@ -0,0 +1,404 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/cache/v9"
+	"golang.org/x/sync/singleflight"
+)
+
+// memoryCleanInterval 过期key清理间隔
+const memoryCleanInterval = time.Minute
+
+// ErrNotInteger 计数器操作的值不是整数
+var ErrNotInteger = errors.New("cache: value is not an integer")
+
+// memoryItem 内存缓存条目，expireAt为零值时永不过期
+type memoryItem struct {
+	value    []byte
+	expireAt time.Time
+}
+
+// memoryTag 标签成员，expireAt为最后一个成员的过期时间
+type memoryTag struct {
+	keys     map[string]struct{}
+	expireAt time.Time
+}
+
+// MemoryCache 纯内存实现的 Rediser，用于本地开发和单元测试，不依赖redis
+type MemoryCache struct {
+	mu         sync.RWMutex
+	items      map[string]memoryItem
+	tags       map[string]memoryTag
+	codec      *Codec             // 编解码，与redis实现保持相同的存储格式
+	keyspace                      // key命名空间前缀
+	group      singleflight.Group // 回源请求合并
+	now        func() time.Time   // 当前时间，测试时可替换
+	defaultTtl time.Duration      // 默认过期时间
+	hits       uint64
+	misses     uint64
+}
+
+// NewMemoryCache 创建内存缓存，ctx结束后停止过期清理
+func NewMemoryCache(ctx context.Context, opts ...Option) *MemoryCache {
+	o := newOptions(opts)
+	mc := &MemoryCache{
+		items:    make(map[string]memoryItem),
+		tags:     make(map[string]memoryTag),
+		codec:    o.codec,
+		keyspace: o.keyspace,
+		now:      timeNow,
+	}
+	go mc.cleanLoop(ctx)
+	return mc
+}
+
+// cleanLoop 定时清理过期key，避免只写不读的key一直占用内存
+func (mc *MemoryCache) cleanLoop(ctx context.Context) {
+	ticker := time.NewTicker(memoryCleanInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			mc.mu.Lock()
+			now := mc.now()
+			for key, item := range mc.items {
+				if item.expired(now) {
+					delete(mc.items, key)
+				}
+			}
+			for tag, t := range mc.tags {
+				if !now.Before(t.expireAt) {
+					delete(mc.tags, tag)
+				}
+			}
+			mc.mu.Unlock()
+		}
+	}
+}
+
+func (item memoryItem) expired(now time.Time) bool {
+	return !item.expireAt.IsZero() && !now.Before(item.expireAt)
+}
+
+// load 读取未过期的条目，调用方需持有锁
+func (mc *MemoryCache) load(key string) (memoryItem, bool) {
+	item, ok := mc.items[key]
+	if !ok || item.expired(mc.now()) {
+		return memoryItem{}, false
+	}
+	return item, true
+}
+
+func (mc *MemoryCache) Get(ctx context.Context, key string, value interface{}) (err error) {
+	key = mc.key(key)
+	mc.mu.RLock()
+	item, ok := mc.load(key)
+	mc.mu.RUnlock()
+
+	if !ok {
+		atomic.AddUint64(&mc.misses, 1)
+		return ErrCacheMiss
+	}
+	atomic.AddUint64(&mc.hits, 1)
+	return mc.codec.Unmarshal(item.value, value)
+}
+
+// GetRedisClient 内存实现没有redis客户端，返回nil
+func (mc *MemoryCache) GetRedisClient() *cache.Cache {
+	return nil
+}
+
+func (mc *MemoryCache) IsExists(ctx context.Context, key string) bool {
+	key = mc.key(key)
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+	_, ok := mc.load(key)
+	return ok
+}
+
+func (mc *MemoryCache) Set(ctx context.Context, key string, value interface{}) (err error) {
+	return mc.SetTtl(ctx, key, value, 0)
+}
+
+// SetTtl 设置key过期时间
+func (mc *MemoryCache) SetTtl(ctx context.Context, key string, value interface{}, ttl time.Duration) (err error) {
+	key = mc.key(key)
+	b, err := mc.codec.Marshal(value)
+	if err != nil {
+		return
+	}
+	mc.mu.Lock()
+	mc.items[key] = memoryItem{value: b, expireAt: mc.now().Add(mc.ttl(ttl))}
+	mc.mu.Unlock()
+	return
+}
+
+// SetWithTags 写入key并打上标签
+func (mc *MemoryCache) SetWithTags(ctx context.Context, key string, value interface{}, ttl time.Duration, tags ...string) (err error) {
+	if err = mc.SetTtl(ctx, key, value, ttl); err != nil {
+		return
+	}
+	expireAt := mc.now().Add(mc.ttl(ttl))
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	for _, tag := range tags {
+		tag = mc.key(TagPrefix + tag)
+		t, ok := mc.tags[tag]
+		if !ok || !mc.now().Before(t.expireAt) {
+			t = memoryTag{keys: make(map[string]struct{})}
+		}
+		t.keys[key] = struct{}{}
+		if expireAt.After(t.expireAt) {
+			t.expireAt = expireAt
+		}
+		mc.tags[tag] = t
+	}
+	return
+}
+
+// InvalidateTags 删除打了任一标签的全部key及标签本身，返回删除的key数量
+func (mc *MemoryCache) InvalidateTags(ctx context.Context, tags ...string) (n int64, err error) {
+	var keys []string
+	mc.mu.Lock()
+	for _, tag := range tags {
+		tag = mc.key(TagPrefix + tag)
+		if t, ok := mc.tags[tag]; ok && mc.now().Before(t.expireAt) {
+			for key := range t.keys {
+				keys = append(keys, key)
+			}
+		}
+		delete(mc.tags, tag)
+	}
+	mc.mu.Unlock()
+	return mc.Delete(ctx, keys...)
+}
+
+// SetNX key不存在时写入，返回是否写入成功
+func (mc *MemoryCache) SetNX(ctx context.Context, key string, value interface{}, ttl time.Duration) (ok bool, err error) {
+	key = mc.key(key)
+	b, err := mc.codec.Marshal(value)
+	if err != nil {
+		return
+	}
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	if _, exists := mc.load(key); exists {
+		return false, nil
+	}
+	mc.items[key] = memoryItem{value: b, expireAt: mc.now().Add(mc.ttl(ttl))}
+	return true, nil
+}
+
+// Delete 删除key，返回删除数量
+func (mc *MemoryCache) Delete(ctx context.Context, keys ...string) (n int64, err error) {
+	keys = mc.keys(keys)
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	for _, key := range keys {
+		if _, ok := mc.load(key); ok {
+			n++
+		}
+		delete(mc.items, key)
+	}
+	return
+}
+
+// Expire 重新设置key的过期时间，与redis一致，ttl<=0时直接删除key
+func (mc *MemoryCache) Expire(ctx context.Context, key string, ttl time.Duration) (ok bool, err error) {
+	key = mc.key(key)
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	item, ok := mc.load(key)
+	if !ok {
+		return false, nil
+	}
+	if ttl <= 0 {
+		delete(mc.items, key)
+		return true, nil
+	}
+	item.expireAt = mc.now().Add(ttl)
+	mc.items[key] = item
+	return true, nil
+}
+
+// TTL 获取key剩余有效期，key不存在时返回 ErrCacheMiss
+func (mc *MemoryCache) TTL(ctx context.Context, key string) (ttl time.Duration, err error) {
+	key = mc.key(key)
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+	item, ok := mc.load(key)
+	if !ok {
+		return 0, ErrCacheMiss
+	}
+	if item.expireAt.IsZero() {
+		return NoExpiration, nil
+	}
+	// 与redis一致，精确到秒
+	return item.expireAt.Sub(mc.now()).Round(time.Second), nil
+}
+
+func (mc *MemoryCache) Incr(ctx context.Context, key string) (n int64, err error) {
+	return mc.IncrBy(ctx, key, 1)
+}
+
+// IncrBy 计数器+value，与redis一致以十进制字符串存储，并保留原有过期时间
+func (mc *MemoryCache) IncrBy(ctx context.Context, key string, value int64) (n int64, err error) {
+	key = mc.key(key)
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	item, ok := mc.load(key)
+	if ok {
+		if n, err = strconv.ParseInt(string(item.value), 10, 64); err != nil {
+			return 0, ErrNotInteger
+		}
+	}
+	n += value
+	item.value = []byte(strconv.FormatInt(n, 10))
+	mc.items[key] = item
+	return
+}
+
+func (mc *MemoryCache) Decr(ctx context.Context, key string) (n int64, err error) {
+	return mc.IncrBy(ctx, key, -1)
+}
+
+func (mc *MemoryCache) DecrBy(ctx context.Context, key string, value int64) (n int64, err error) {
+	return mc.IncrBy(ctx, key, -value)
+}
+
+// MGet 批量获取编码后的值，key不存在时对应nil
+func (mc *MemoryCache) MGet(ctx context.Context, keys ...string) (values [][]byte, err error) {
+	if len(keys) == 0 {
+		return
+	}
+	mc.mu.RLock()
+	defer mc.mu.RUnlock()
+	values = make([][]byte, len(keys))
+	for i, key := range keys {
+		if item, ok := mc.load(mc.key(key)); ok {
+			values[i] = append([]byte(nil), item.value...)
+		}
+	}
+	return
+}
+
+// MSet 批量写入key-value
+func (mc *MemoryCache) MSet(ctx context.Context, values map[string]interface{}, ttl time.Duration) (err error) {
+	encoded := make(map[string][]byte, len(values))
+	for key, value := range values {
+		if encoded[mc.key(key)], err = mc.codec.Marshal(value); err != nil {
+			return
+		}
+	}
+	expireAt := mc.now().Add(mc.ttl(ttl))
+	mc.mu.Lock()
+	for key, b := range encoded {
+		mc.items[key] = memoryItem{value: b, expireAt: expireAt}
+	}
+	mc.mu.Unlock()
+	return
+}
+
+// Scan 遍历匹配的key，match 支持redis glob规则（* ? [abc]），count无实际意义
+func (mc *MemoryCache) Scan(ctx context.Context, match string, count int64, fn func(key string) error) (err error) {
+	re, err := globToRegexp(mc.pattern(match))
+	if err != nil {
+		return
+	}
+	// 先收集key再回调，回调中可以安全地读写缓存
+	var keys []string
+	mc.mu.RLock()
+	for key := range mc.items {
+		if _, ok := mc.load(key); ok && re.MatchString(key) {
+			keys = append(keys, key)
+		}
+	}
+	mc.mu.RUnlock()
+
+	for _, key := range keys {
+		if err = ctx.Err(); err != nil {
+			return
+		}
+		if err = fn(mc.trim(key)); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (mc *MemoryCache) Unmarshal(b []byte, value interface{}) (err error) {
+	return mc.codec.Unmarshal(b, value)
+}
+
+func (mc *MemoryCache) Marshal(value interface{}) (b []byte, err error) {
+	return mc.codec.Marshal(value)
+}
+
+// GetOrLoad 读取缓存，未命中时调用loader回源并写入缓存
+func (mc *MemoryCache) GetOrLoad(ctx context.Context, key string, ttl time.Duration, loader LoaderFunc,
+	value interface{}, opts ...LoadOption) (err error) {
+	return getOrLoad(ctx, mc, &mc.group, key, ttl, loader, value, opts...)
+}
+
+// Stats 内存缓存只有本地一层
+func (mc *MemoryCache) Stats() Stats {
+	return Stats{Local: TierStats{
+		Hits:   atomic.LoadUint64(&mc.hits),
+		Misses: atomic.LoadUint64(&mc.misses),
+	}}
+}
+
+// ttl 未设置过期时间时使用默认有效期
+func (mc *MemoryCache) ttl(ttl time.Duration) time.Duration {
+	if ttl <= 0 {
+		ttl = mc.defaultTtl
+	}
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return ttl
+}
+
+// globToRegexp 将redis glob匹配规则转换为正则
+func globToRegexp(pattern string) (*regexp.Regexp, error) {
+	if pattern == "" {
+		pattern = "*"
+	}
+	var sb strings.Builder
+	sb.WriteString("^")
+	for i := 0; i < len(pattern); i++ {
+		switch c := pattern[i]; c {
+		case '*':
+			sb.WriteString(".*")
+		case '?':
+			sb.WriteString(".")
+		case '\\':
+			if i+1 < len(pattern) {
+				i++
+				sb.WriteString(regexp.QuoteMeta(pattern[i : i+1]))
+			}
+		case '[':
+			end := strings.IndexByte(pattern[i:], ']')
+			if end < 0 {
+				sb.WriteString(regexp.QuoteMeta("["))
+				continue
+			}
+			sb.WriteString(pattern[i : i+end+1])
+			i += end
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	sb.WriteString("$")
+	return regexp.Compile(sb.String())
+}
@@ -0,0 +1,40 @@
+package cache
+
+import (
+	"context"
+	"testing"
+)
+
+func TestMemoryCache(t *testing.T) {
+	testRediser(t, func(t *testing.T) backend {
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+
+		// 内存实现使用 timeNow 作为时钟，无需额外推进
+		return backend{cache: NewMemoryCache(ctx)}
+	})
+}
+
+func TestGlobToRegexp(t *testing.T) {
+	cases := []struct {
+		pattern, key string
+		want         bool
+	}{
+		{"*", "anything", true},
+		{"user:*", "user:1", true},
+		{"user:?", "user:10", false},
+		{"user:[12]", "user:2", true},
+		{"user:[12]", "user:3", false},
+		{`a\*b`, "a*b", true},
+		{"a.b", "axb", false},
+	}
+	for _, c := range cases {
+		re, err := globToRegexp(c.pattern)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := re.MatchString(c.key); got != c.want {
+			t.Errorf("glob %s match %s want %v but get %v", c.pattern, c.key, c.want, got)
+		}
+	}
+}
@@ -2,55 +2,352 @@ package cache
 
 import (
 	"context"
-	"github.com/go-redis/cache/v9"
+	"sync"
 	"time"
+
+	"github.com/go-redis/cache/v9"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
 )
 
+const (
+	// DefaultTTL 未设置过期时间时的默认有效期
+	DefaultTTL = time.Hour
+	// NoExpiration TTL 返回该值表示key永不过期
+	NoExpiration = time.Duration(-1)
+)
+
+// ErrCacheMiss key不存在
+var ErrCacheMiss = cache.ErrCacheMiss
+
 // Rediser 接口实
 type Rediser interface {
-	Get(key string, value interface{}) (err error)                       // 获取key-value
-	GetRedisClient() *cache.Cache                                        // 操作redis客户端
-	IsExists(key string) bool                                            // 判断key是否存在
-	Set(Key string, value interface{}) (err error)                       // 添加key-value
-	SetTtl(key string, value interface{}, ttl time.Duration) (err error) // 设置key超时时间
+	Get(ctx context.Context, key string, value interface{}) (err error)                                            // 获取key-value
+	GetRedisClient() *cache.Cache                                                                                  // 操作redis客户端
+	IsExists(ctx context.Context, key string) bool                                                                 // 判断key是否存在
+	Set(ctx context.Context, key string, value interface{}) (err error)                                            // 添加key-value
+	SetTtl(ctx context.Context, key string, value interface{}, ttl time.Duration) (err error)                      // 设置key超时时间
+	SetNX(ctx context.Context, key string, value interface{}, ttl time.Duration) (ok bool, err error)              // key不存在时才写入
+	SetWithTags(ctx context.Context, key string, value interface{}, ttl time.Duration, tags ...string) (err error) // 写入key并打上标签
+	InvalidateTags(ctx context.Context, tags ...string) (n int64, err error)                                       // 删除打了任一标签的全部key
+	Delete(ctx context.Context, keys ...string) (n int64, err error)                                               // 删除key，返回删除数量
+	Expire(ctx context.Context, key string, ttl time.Duration) (ok bool, err error)                                // 重新设置过期时间
+	TTL(ctx context.Context, key string) (ttl time.Duration, err error)                                            // 剩余有效期，永不过期返回 NoExpiration
+	Incr(ctx context.Context, key string) (n int64, err error)                                                     // 计数器+1
+	IncrBy(ctx context.Context, key string, value int64) (n int64, err error)                                      // 计数器+value
+	Decr(ctx context.Context, key string) (n int64, err error)                                                     // 计数器-1
+	DecrBy(ctx context.Context, key string, value int64) (n int64, err error)                                      // 计数器-value
+	MGet(ctx context.Context, keys ...string) (values [][]byte, err error)                                         // 批量获取编码后的值，key不存在时对应nil
+	MSet(ctx context.Context, values map[string]interface{}, ttl time.Duration) (err error)                        // 批量写入key-value
+	Scan(ctx context.Context, match string, count int64, fn func(key string) error) (err error)                    // 基于SCAN遍历匹配的key
+	Unmarshal(b []byte, value interface{}) (err error)                                                             // 解码 MGet 返回的值
+	Marshal(value interface{}) (b []byte, err error)                                                               // 按缓存格式编码
+	GetOrLoad(ctx context.Context, key string, ttl time.Duration, loader LoaderFunc,
+		value interface{}, opts ...LoadOption) (err error) // 读取缓存，未命中时回源加载并写入缓存
+	Stats() Stats // 各层缓存命中统计
 }
 
 // RedisCache 封装操作客户端
 type RedisCache struct {
-	ctx        context.Context
+	client     redis.UniversalClient
 	proxy      *cache.Cache
-	defaultTtl time.Duration // 默认过期时间
+	keyspace                       // key命名空间前缀
+	local      *countingLocalCache // 本地缓存，未开启时为nil
+	instanceID string              // 实例标识，用于忽略自身发出的失效通知
+	channel    string              // 失效通知频道
+	group      singleflight.Group  // 回源请求合并
+	breaker    *breaker            // 熔断器，未开启时为nil
+	defaultTtl time.Duration       // 默认过期时间
 
 }
 
-func (rc *RedisCache) Get(key string, value interface{}) (err error) {
-	err = rc.proxy.Get(rc.ctx, key, value)
+// Get 获取key-value，熔断时从本地缓存读取
+func (rc *RedisCache) Get(ctx context.Context, key string, value interface{}) (err error) {
+	key = rc.key(key)
+	if err = rc.allow(); err != nil {
+		return rc.getLocal(key, value, err)
+	}
+	defer rc.record(&err)
+	err = rc.proxy.Get(ctx, key, value)
 	return
 }
 func (rc *RedisCache) GetRedisClient() *cache.Cache {
 	return rc.proxy
 }
-func (rc *RedisCache) IsExists(key string) bool {
-	return rc.proxy.Exists(rc.ctx, key)
+
+// GetUniversalClient 获取底层redis客户端，用于分布式锁、限流等需要原生命令的场景
+func (rc *RedisCache) GetUniversalClient() redis.UniversalClient {
+	return rc.client
 }
-func (rc *RedisCache) Set(Key string, value interface{}) (err error) {
-	rc.SetTtl(Key, value, 0)
+func (rc *RedisCache) IsExists(ctx context.Context, key string) bool {
+	if rc.allow() != nil {
+		return false
+	}
+	err := rc.proxy.Get(ctx, rc.key(key), nil)
+	rc.record(&err)
+	return err == nil
+}
+func (rc *RedisCache) Set(ctx context.Context, key string, value interface{}) (err error) {
+	err = rc.SetTtl(ctx, key, value, 0)
 	return
 }
 
 // SetTtl 设置key过期时间
-func (rc *RedisCache) SetTtl(key string, value interface{}, ttl time.Duration) (err error) {
-	if ttl <= 0 {
-		ttl = rc.defaultTtl
+func (rc *RedisCache) SetTtl(ctx context.Context, key string, value interface{}, ttl time.Duration) (err error) {
+	key = rc.key(key)
+	if err = rc.allow(); err != nil {
+		return
 	}
+	defer rc.record(&err)
 	item := cache.Item{
-		Ctx:   rc.ctx,
+		Ctx:   ctx,
 		Key:   key,
 		Value: value,
+		TTL:   rc.ttl(ttl),
+	}
+	if err = rc.proxy.Set(&item); err == nil {
+		rc.notify(ctx, key)
+	}
+	return
+}
+
+// SetNX key不存在时写入，返回是否写入成功
+func (rc *RedisCache) SetNX(ctx context.Context, key string, value interface{}, ttl time.Duration) (ok bool, err error) {
+	key = rc.key(key)
+	if err = rc.allow(); err != nil {
+		return
+	}
+	defer rc.record(&err)
+	b, err := rc.proxy.Marshal(value)
+	if err != nil {
+		return
+	}
+	if ok, err = rc.client.SetNX(ctx, key, b, rc.ttl(ttl)).Result(); ok {
+		// 本地缓存中可能残留旧值
+		rc.invalidate(ctx, key)
+	}
+	return
+}
+
+// Delete 删除key，同时清除本地缓存
+func (rc *RedisCache) Delete(ctx context.Context, keys ...string) (n int64, err error) {
+	if len(keys) == 0 {
+		return
+	}
+	if err = rc.allow(); err != nil {
+		return
+	}
+	defer rc.record(&err)
+	keys = rc.keys(keys)
+	defer rc.invalidate(ctx, keys...)
+	if !rc.isCluster() {
+		return rc.client.Del(ctx, keys...).Result()
+	}
+	// 集群模式下多个key可能分布在不同slot，逐个删除
+	cmds, err := rc.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, key := range keys {
+			pipe.Del(ctx, key)
+		}
+		return nil
+	})
+	for _, cmd := range cmds {
+		n += cmd.(*redis.IntCmd).Val()
+	}
+	return
+}
+
+// Expire 重新设置key的过期时间，key不存在时返回false
+func (rc *RedisCache) Expire(ctx context.Context, key string, ttl time.Duration) (ok bool, err error) {
+	key = rc.key(key)
+	if err = rc.allow(); err != nil {
+		return
+	}
+	defer rc.record(&err)
+	if ok, err = rc.client.Expire(ctx, key, ttl).Result(); ok {
+		// 其他实例本地缓存中的副本不随redis过期，需要清除
+		rc.invalidate(ctx, key)
+	}
+	return
+}
+
+// TTL 获取key剩余有效期，key不存在时返回 ErrCacheMiss
+func (rc *RedisCache) TTL(ctx context.Context, key string) (ttl time.Duration, err error) {
+	key = rc.key(key)
+	if err = rc.allow(); err != nil {
+		return
+	}
+	defer rc.record(&err)
+	if ttl, err = rc.client.TTL(ctx, key).Result(); err != nil {
+		return
+	}
+	switch ttl {
+	case -2:
+		return 0, ErrCacheMiss
+	case -1:
+		return NoExpiration, nil
+	}
+	return
+}
+
+// Incr 计数器+1，计数器以整数形式存储，读取时使用 IncrBy(ctx, key, 0)
+func (rc *RedisCache) Incr(ctx context.Context, key string) (n int64, err error) {
+	return rc.IncrBy(ctx, key, 1)
+}
+
+// IncrBy 计数器+value
+func (rc *RedisCache) IncrBy(ctx context.Context, key string, value int64) (n int64, err error) {
+	key = rc.key(key)
+	if err = rc.allow(); err != nil {
+		return
 	}
-	if ttl > 0 {
-		item.TTL = ttl
+	defer rc.record(&err)
+	defer rc.invalidate(ctx, key)
+	return rc.client.IncrBy(ctx, key, value).Result()
+}
+
+// Decr 计数器-1
+func (rc *RedisCache) Decr(ctx context.Context, key string) (n int64, err error) {
+	return rc.IncrBy(ctx, key, -1)
+}
+
+// DecrBy 计数器-value
+func (rc *RedisCache) DecrBy(ctx context.Context, key string, value int64) (n int64, err error) {
+	return rc.IncrBy(ctx, key, -value)
+}
+
+// MGet 批量获取编码后的值，通过 Unmarshal 或 MGetAs 解码
+func (rc *RedisCache) MGet(ctx context.Context, keys ...string) (values [][]byte, err error) {
+	if len(keys) == 0 {
+		return
+	}
+	if err = rc.allow(); err != nil {
+		return
+	}
+	defer rc.record(&err)
+	keys = rc.keys(keys)
+	values = make([][]byte, len(keys))
+	if rc.isCluster() {
+		// 集群模式下MGET不支持跨slot，通过pipeline逐个GET
+		cmds, err := rc.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for _, key := range keys {
+				pipe.Get(ctx, key)
+			}
+			return nil
+		})
+		if err != nil && err != redis.Nil {
+			return nil, err
+		}
+		for i, cmd := range cmds {
+			if b, err := cmd.(*redis.StringCmd).Bytes(); err == nil {
+				values[i] = b
+			}
+		}
+		return values, nil
+	}
+
+	result, err := rc.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, err
 	}
-	err = rc.proxy.Set(&item)
+	for i, v := range result {
+		if s, ok := v.(string); ok {
+			values[i] = []byte(s)
+		}
+	}
+	return
+}
+
+// MSet 批量写入key-value，MSET不支持过期时间，这里通过pipeline逐个SET
+func (rc *RedisCache) MSet(ctx context.Context, values map[string]interface{}, ttl time.Duration) (err error) {
+	if err = rc.allow(); err != nil {
+		return
+	}
+	defer rc.record(&err)
+	if len(values) == 0 {
+		return
+	}
+	ttl = rc.ttl(ttl)
+	keys := make([]string, 0, len(values))
+	pipe := rc.client.Pipeline()
+	for key, value := range values {
+		b, err := rc.proxy.Marshal(value)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, rc.key(key))
+		pipe.Set(ctx, rc.key(key), b, ttl)
+	}
+	defer rc.invalidate(ctx, keys...)
+	_, err = pipe.Exec(ctx)
 	return
 }
+
+// Scan 基于SCAN游标遍历匹配的key，不会像KEYS一样阻塞redis；fn返回错误时停止遍历
+// 集群模式下遍历所有主节点，fn 会被串行调用
+func (rc *RedisCache) Scan(ctx context.Context, match string, count int64, fn func(key string) error) (err error) {
+	if err = rc.allow(); err != nil {
+		return
+	}
+	defer rc.record(&err)
+	// 返回给调用方的key去掉命名空间前缀
+	match, trimmed := rc.pattern(match), func(key string) error {
+		return fn(rc.trim(key))
+	}
+	cluster, ok := rc.client.(*redis.ClusterClient)
+	if !ok {
+		return scanNode(ctx, rc.client, match, count, trimmed)
+	}
+
+	var mu sync.Mutex
+	return cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+		return scanNode(ctx, node, match, count, func(key string) error {
+			mu.Lock()
+			defer mu.Unlock()
+			return trimmed(key)
+		})
+	})
+}
+
+// scanNode 遍历单个节点
+func scanNode(ctx context.Context, client redis.Cmdable, match string, count int64, fn func(key string) error) (err error) {
+	iter := client.Scan(ctx, 0, match, count).Iterator()
+	for iter.Next(ctx) {
+		if err = fn(iter.Val()); err != nil {
+			return
+		}
+	}
+	return iter.Err()
+}
+
+// isCluster 是否为集群模式
+func (rc *RedisCache) isCluster() bool {
+	_, ok := rc.client.(*redis.ClusterClient)
+	return ok
+}
+
+// Unmarshal 解码 MGet 返回的值
+func (rc *RedisCache) Unmarshal(b []byte, value interface{}) (err error) {
+	return rc.proxy.Unmarshal(b, value)
+}
+
+// Marshal 按缓存格式编码
+func (rc *RedisCache) Marshal(value interface{}) (b []byte, err error) {
+	return rc.proxy.Marshal(value)
+}
+
+// GetOrLoad 读取缓存，未命中时调用loader回源并写入缓存；同一进程内同一个key只会有一个loader在执行
+func (rc *RedisCache) GetOrLoad(ctx context.Context, key string, ttl time.Duration, loader LoaderFunc,
+	value interface{}, opts ...LoadOption) (err error) {
+	return getOrLoad(ctx, rc, &rc.group, key, ttl, loader, value, opts...)
+}
+
+// ttl 未设置过期时间时使用默认有效期
+func (rc *RedisCache) ttl(ttl time.Duration) time.Duration {
+	if ttl <= 0 {
+		ttl = rc.defaultTtl
+	}
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return ttl
+}
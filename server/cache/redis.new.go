@@ -2,6 +2,7 @@ package cache
 
 import (
 	"context"
+	"fmt"
 	"github.com/Domingor/go-blackbox/server/zaplog"
 	"github.com/go-redis/cache/v9"
 	"github.com/redis/go-redis/v9"
@@ -9,35 +10,117 @@ import (
 	"time"
 )
 
-type RedisOptions redis.Options
+// RedisOptions redis连接参数，兼容单节点、哨兵、集群三种部署模式
+type RedisOptions redis.UniversalOptions
 
 var (
-	once        sync.Once
-	redisCacher *RedisCache
+	mu     sync.Mutex
+	cacher Rediser
 )
 
-// Init 初始化缓存配置
-func Init(ctx context.Context, redisOptions RedisOptions) *RedisCache {
+// Init 初始化缓存配置，根据 Driver 选择redis或内存实现；首次连接失败时按退避策略重试，
+// 重试耗尽返回错误，之后可再次调用 Init 重新初始化
+func Init(ctx context.Context, redisConfig *RedisConfig) (Rediser, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if cacher != nil {
+		return cacher, nil
+	}
 
-	once.Do(func() {
-		options := redis.Options(redisOptions)
-		rdb := redis.NewClient(&options)
+	if redisConfig == nil {
+		return nil, fmt.Errorf("redis config is nil")
+	}
+	opts, err := redisConfig.cacheOptions()
+	if err != nil {
+		return nil, err
+	}
+	if redisConfig.Driver == DriverMemory {
+		cacher = NewMemoryCache(ctx, opts...)
+		return cacher, nil
+	}
 
-		if ping := rdb.Ping(ctx); ping != nil {
-			zaplog.SugaredLogger.Debug("ping redis error")
-			return
+	rdb, err := NewUniversalClient(redisConfig.Mode, redisConfig.Options())
+	if err != nil {
+		return nil, err
+	}
+	if err = connect(ctx, rdb, redisConfig.Retry); err != nil {
+		_ = rdb.Close()
+		return nil, err
+	}
+
+	rc := newRedisCache(ctx, rdb, redisConfig.LocalCache, opts...)
+	rc.breaker = newBreaker(redisConfig.Breaker)
+	cacher = rc
+	return cacher, nil
+}
+
+// connect 按指数退避重试ping，直到成功、重试次数耗尽或ctx结束
+func connect(ctx context.Context, rdb redis.UniversalClient, retry RetryConfig) (err error) {
+	retry = retry.withDefaults()
+	backoff := retry.Backoff
+	for attempt := 1; ; attempt++ {
+		if err = rdb.Ping(ctx).Err(); err == nil {
+			return nil
 		}
+		if attempt >= retry.Attempts {
+			return fmt.Errorf("ping redis failed after %d attempts: %w", attempt, err)
+		}
+		zaplog.SugaredLogger.Warnf("ping redis failed, retry in %s (%d/%d): %s", backoff, attempt, retry.Attempts, err)
 
-		cacheProxy := cache.New(&cache.Options{
-			Redis:      rdb,
-			LocalCache: cache.NewTinyLFU(1000, time.Minute),
-		})
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		if backoff *= 2; backoff > retry.MaxBackoff {
+			backoff = retry.MaxBackoff
+		}
+	}
+}
 
-		redisCacher = &RedisCache{
-			ctx:   ctx,
-			proxy: cacheProxy,
-			//defaultTtl: 0,
+// NewUniversalClient 根据部署模式创建redis客户端
+func NewUniversalClient(mode string, redisOptions RedisOptions) (redis.UniversalClient, error) {
+	options := redis.UniversalOptions(redisOptions)
+	switch mode {
+	case ModeCluster:
+		return redis.NewClusterClient(options.Cluster()), nil
+	case ModeSentinel:
+		if options.MasterName == "" {
+			return nil, fmt.Errorf("redis sentinel mode requires masterName")
 		}
-	})
-	return redisCacher
+		return redis.NewFailoverClient(options.Failover()), nil
+	case ModeStandalone, "":
+		return redis.NewClient(options.Simple()), nil
+	}
+	return nil, fmt.Errorf("unknown redis mode %s", mode)
+}
+
+// newRedisCache 创建缓存客户端，开启本地缓存时订阅失效通知
+func newRedisCache(ctx context.Context, rdb redis.UniversalClient, localConfig LocalCacheConfig, opts ...Option) *RedisCache {
+	o := newOptions(opts)
+	rc := &RedisCache{
+		client:     rdb,
+		keyspace:   o.keyspace,
+		instanceID: newInstanceID(),
+		//defaultTtl: 0,
+	}
+
+	cacheOptions := &cache.Options{
+		Redis:        rdb,
+		StatsEnabled: true,
+		Marshal:      o.codec.Marshal,
+		Unmarshal:    o.codec.Unmarshal,
+	}
+	if !localConfig.Disable {
+		localConfig = localConfig.withDefaults()
+		rc.local = &countingLocalCache{LocalCache: cache.NewTinyLFU(localConfig.Size, localConfig.TTL)}
+		rc.channel = localConfig.InvalidationChannel
+		cacheOptions.LocalCache = rc.local
+	}
+	rc.proxy = cache.New(cacheOptions)
+
+	if rc.local != nil {
+		rc.subscribeInvalidation(ctx)
+	}
+	return rc
 }
@@ -0,0 +1,131 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// newMiniRedis 启动内存版redis服务
+func newMiniRedis(t *testing.T) (*miniredis.Miniredis, redis.UniversalClient) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	return server, client
+}
+
+func TestRedisCache(t *testing.T) {
+	testRediser(t, func(t *testing.T) backend {
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+
+		server, client := newMiniRedis(t)
+		rc := newRedisCache(ctx, client, LocalCacheConfig{Disable: true})
+		return backend{cache: rc, advance: func(d time.Duration) {
+			server.FastForward(d)
+		}}
+	})
+}
+
+func TestLocalCacheInvalidation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	server, client := newMiniRedis(t)
+	other := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer other.Close()
+
+	a := newRedisCache(ctx, client, LocalCacheConfig{})
+	b := newRedisCache(ctx, other, LocalCacheConfig{})
+	// 等待订阅生效
+	time.Sleep(50 * time.Millisecond)
+
+	var v string
+	_ = a.Set(ctx, "k", "v1")
+	if err := b.Get(ctx, "k", &v); err != nil || v != "v1" {
+		t.Fatalf("want v1 but get %s %v", v, err)
+	}
+	_ = a.Set(ctx, "k", "v2")
+	time.Sleep(50 * time.Millisecond)
+	if err := b.Get(ctx, "k", &v); err != nil || v != "v2" {
+		t.Errorf("local cache should be invalidated, want v2 but get %s %v", v, err)
+	}
+
+	stats := b.Stats()
+	if stats.Local.Misses != 2 || stats.Remote.Hits != 2 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+	_ = b.Get(ctx, "k", &v)
+	if b.Stats().Local.Hits != 1 {
+		t.Errorf("want 1 local hit but get %+v", b.Stats())
+	}
+	// 缩短有效期后其他实例不再使用本地缓存的副本
+	if ok, err := a.Expire(ctx, "k", time.Millisecond); !ok || err != nil {
+		t.Fatalf("expire failed %v %v", ok, err)
+	}
+	time.Sleep(50 * time.Millisecond)
+	server.FastForward(time.Second)
+	if err := b.Get(ctx, "k", &v); !errors.Is(err, ErrCacheMiss) {
+		t.Errorf("expired key should miss on other instance but get %s %v", v, err)
+	}
+}
+
+func TestRedisCacheCluster(t *testing.T) {
+	testRediser(t, func(t *testing.T) backend {
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+
+		// miniredis 以单节点承载全部slot，可模拟集群客户端
+		server := miniredis.RunT(t)
+		client, err := NewUniversalClient(ModeCluster, RedisOptions{Addrs: []string{server.Addr()}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = client.Close() })
+
+		rc := newRedisCache(ctx, client, LocalCacheConfig{Disable: true})
+		return backend{cache: rc, advance: func(d time.Duration) {
+			server.FastForward(d)
+		}}
+	})
+}
+
+func TestNewUniversalClient(t *testing.T) {
+	conf := RedisConfig{Addr: "127.0.0.1:6379", Addrs: []string{"127.0.0.1:6380"}}
+	if addrs := conf.Options().Addrs; len(addrs) != 2 || addrs[0] != "127.0.0.1:6379" {
+		t.Errorf("addr should be merged into addrs but get %v", addrs)
+	}
+
+	cases := []struct {
+		mode    string
+		options RedisOptions
+		want    interface{}
+		wantErr bool
+	}{
+		{"", RedisOptions{}, &redis.Client{}, false},
+		{ModeStandalone, RedisOptions{}, &redis.Client{}, false},
+		{ModeCluster, RedisOptions{}, &redis.ClusterClient{}, false},
+		{ModeSentinel, RedisOptions{MasterName: "mymaster"}, &redis.Client{}, false},
+		{ModeSentinel, RedisOptions{}, nil, true},
+		{"unknown", RedisOptions{}, nil, true},
+	}
+	for _, c := range cases {
+		client, err := NewUniversalClient(c.mode, c.options)
+		if (err != nil) != c.wantErr {
+			t.Errorf("mode %q want error %v but get %v", c.mode, c.wantErr, err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		if got, want := fmt.Sprintf("%T", client), fmt.Sprintf("%T", c.want); got != want {
+			t.Errorf("mode %q want %s but get %s", c.mode, want, got)
+		}
+		_ = client.Close()
+	}
+}
@@ -0,0 +1,89 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// TagPrefix 标签集合key前缀，集合中保存打了该标签的缓存key
+const TagPrefix = "tag:"
+
+// 添加标签成员，集合有效期不短于成员有效期，最后一个成员过期后集合随之过期
+var tagAddScript = redis.NewScript(`
+local ttl = tonumber(ARGV[1])
+redis.call("SADD", KEYS[1], unpack(ARGV, 2))
+if redis.call("PTTL", KEYS[1]) < ttl then
+	redis.call("PEXPIRE", KEYS[1], ttl)
+end
+return 1
+`)
+
+// 取出标签全部成员并删除标签集合
+var tagPopScript = redis.NewScript(`
+local members = redis.call("SMEMBERS", KEYS[1])
+redis.call("DEL", KEYS[1])
+return members
+`)
+
+// SetWithTags 写入key并打上标签，之后可通过 InvalidateTags 按标签批量删除
+func (rc *RedisCache) SetWithTags(ctx context.Context, key string, value interface{}, ttl time.Duration, tags ...string) (err error) {
+	if err = rc.SetTtl(ctx, key, value, ttl); err != nil || len(tags) == 0 {
+		return
+	}
+	if err = rc.allow(); err != nil {
+		return
+	}
+	defer rc.record(&err)
+
+	// 每个标签集合单独执行脚本，集群模式下标签与key可以位于不同slot；pipeline中无法处理NOSCRIPT，直接使用EVAL
+	ttl = rc.ttl(ttl)
+	_, err = rc.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, tag := range tags {
+			tagAddScript.Eval(ctx, pipe, []string{rc.tagKey(tag)}, ttl.Milliseconds(), key)
+		}
+		return nil
+	})
+	return
+}
+
+// InvalidateTags 删除打了任一标签的全部key及标签本身，返回删除的key数量
+func (rc *RedisCache) InvalidateTags(ctx context.Context, tags ...string) (n int64, err error) {
+	keys, err := rc.popTags(ctx, tags)
+	if err != nil || len(keys) == 0 {
+		return
+	}
+	return rc.Delete(ctx, keys...)
+}
+
+// popTags 取出并删除标签集合，返回去重后的成员key
+func (rc *RedisCache) popTags(ctx context.Context, tags []string) (keys []string, err error) {
+	if len(tags) == 0 {
+		return
+	}
+	if err = rc.allow(); err != nil {
+		return
+	}
+	defer rc.record(&err)
+
+	seen := make(map[string]struct{})
+	for _, tag := range tags {
+		members, err := tagPopScript.Run(ctx, rc.client, []string{rc.tagKey(tag)}).StringSlice()
+		if err != nil {
+			return nil, err
+		}
+		for _, member := range members {
+			if _, ok := seen[member]; !ok {
+				seen[member] = struct{}{}
+				keys = append(keys, member)
+			}
+		}
+	}
+	return
+}
+
+// tagKey 标签集合key
+func (rc *RedisCache) tagKey(tag string) string {
+	return rc.key(TagPrefix + tag)
+}
@@ -0,0 +1,196 @@
+package cronjobs
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Domingor/go-blackbox/server/lock"
+	"github.com/Domingor/go-blackbox/server/zaplog"
+)
+
+/**
+* @Description: 多实例部署时的任务协调：Singleton 任务每次只在一个实例执行，LeaderOnly 任务只在选举出的主节点执行
+ */
+
+const (
+	// LeaderKey 主节点选举使用的锁名称
+	LeaderKey = "cron:leader"
+	// singletonPrefix Singleton 任务锁名称前缀
+	singletonPrefix = "cron:"
+	// singletonTTL Singleton 任务锁有效期，执行期间自动续期
+	singletonTTL = 30 * time.Second
+	// singletonMaxHold 任务结束后锁最多继续保留的时长，避免时钟偏差导致其他实例在同一周期重复执行
+	singletonMaxHold = 10 * time.Second
+	// leaderTTL 主节点租约有效期，主节点失联后其他实例最迟在该时长后接管
+	leaderTTL = 15 * time.Second
+)
+
+// ErrLocked 锁已被其他实例持有
+var ErrLocked = errors.New("cronjobs: locked by another instance")
+
+// Lease 已获取的集群锁
+type Lease interface {
+	Lost() <-chan struct{}                                 // 锁丢失时关闭
+	Release(ctx context.Context, hold time.Duration) error // 释放锁，hold>0时继续保留hold时长
+}
+
+// Locker 集群锁，由redis或postgres实现
+type Locker interface {
+	TryLock(ctx context.Context, key string, ttl time.Duration) (Lease, error) // 获取失败返回 ErrLocked
+}
+
+// redisLocker 基于redis分布式锁
+type redisLocker struct {
+	locker *lock.Locker
+}
+
+// NewRedisLocker 基于redis分布式锁创建集群锁
+func NewRedisLocker(locker *lock.Locker) Locker {
+	return &redisLocker{locker: locker}
+}
+
+func (rl *redisLocker) TryLock(ctx context.Context, key string, ttl time.Duration) (Lease, error) {
+	lk, err := rl.locker.Acquire(ctx, key, ttl)
+	if errors.Is(err, lock.ErrNotAcquired) {
+		return nil, ErrLocked
+	}
+	if err != nil {
+		return nil, err
+	}
+	return redisLease{lk}, nil
+}
+
+type redisLease struct {
+	*lock.Lock
+}
+
+func (rl redisLease) Release(ctx context.Context, hold time.Duration) error {
+	err := rl.Lock.Hold(ctx, hold)
+	if errors.Is(err, lock.ErrNotHeld) {
+		return nil
+	}
+	return err
+}
+
+// pgLocker 基于postgres会话级advisory lock，锁与连接绑定，持有期间占用一个连接
+type pgLocker struct {
+	db *sql.DB
+}
+
+// NewPgLocker 基于postgres advisory lock创建集群锁
+func NewPgLocker(db *sql.DB) Locker {
+	return &pgLocker{db: db}
+}
+
+func (pl *pgLocker) TryLock(ctx context.Context, key string, ttl time.Duration) (Lease, error) {
+	conn, err := pl.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	id := advisoryKey(key)
+	var ok bool
+	if err = conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", id).Scan(&ok); err != nil || !ok {
+		_ = conn.Close()
+		if err == nil {
+			err = ErrLocked
+		}
+		return nil, err
+	}
+
+	lease := &pgLease{conn: conn, id: id, lost: make(chan struct{}), stop: make(chan struct{})}
+	go lease.keepalive(ttl / 3)
+	return lease, nil
+}
+
+// advisoryKey 锁名称转换为advisory lock使用的bigint
+func advisoryKey(key string) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	return int64(h.Sum64())
+}
+
+type pgLease struct {
+	conn     *sql.Conn
+	id       int64
+	lost     chan struct{}
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func (pl *pgLease) Lost() <-chan struct{} {
+	return pl.lost
+}
+
+// keepalive 定期检查连接，连接断开时锁随会话释放
+func (pl *pgLease) keepalive(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-pl.stop:
+			return
+		case <-ticker.C:
+			if err := pl.conn.PingContext(context.Background()); err != nil {
+				zaplog.SugaredLogger.Warnf("advisory lock %d lost: %s", pl.id, err)
+				close(pl.lost)
+				return
+			}
+		}
+	}
+}
+
+func (pl *pgLease) Release(ctx context.Context, hold time.Duration) error {
+	pl.stopOnce.Do(func() { close(pl.stop) })
+	unlock := func() {
+		_, _ = pl.conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", pl.id)
+		_ = pl.conn.Close()
+	}
+	if hold > 0 {
+		time.AfterFunc(hold, unlock)
+		return nil
+	}
+	unlock()
+	return nil
+}
+
+// elector 基于集群锁的主节点选举，持有 LeaderKey 的实例为主节点
+type elector struct {
+	locker Locker
+	leader int32
+}
+
+// IsLeader 当前实例是否为主节点
+func (e *elector) IsLeader() bool {
+	return atomic.LoadInt32(&e.leader) == 1
+}
+
+// campaign 持续竞选主节点直到ctx结束，失去主节点身份后重新竞选
+func (e *elector) campaign(ctx context.Context) {
+	for {
+		lease, err := e.locker.TryLock(ctx, LeaderKey, leaderTTL)
+		if err == nil {
+			atomic.StoreInt32(&e.leader, 1)
+			zaplog.SugaredLogger.Info("cron leader elected")
+			select {
+			case <-lease.Lost():
+				zaplog.SugaredLogger.Warn("cron leader lost")
+			case <-ctx.Done():
+			}
+			atomic.StoreInt32(&e.leader, 0)
+			_ = lease.Release(context.Background(), 0)
+		} else if !errors.Is(err, ErrLocked) {
+			zaplog.SugaredLogger.Debugf("cron leader campaign failed: %s", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(leaderTTL / 3):
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package cronjobs
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Domingor/go-blackbox/server/lock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/robfig/cron/v3"
+)
+
+// newCluster 创建共享同一redis的n个注册表，模拟多实例部署
+func newCluster(t *testing.T, n int) (*miniredis.Miniredis, []*Registry) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	registries := make([]*Registry, n)
+	for i := range registries {
+		registries[i] = NewRegistry(cron.New(cron.WithSeconds()))
+		registries[i].UseLocker(ctx, NewRedisLocker(lock.New(client)))
+	}
+	return server, registries
+}
+
+// tick 模拟一次调度触发
+func tick(r *Registry, name string) {
+	r.mu.RLock()
+	rj := r.jobs[name]
+	r.mu.RUnlock()
+	r.wrap(rj)()
+}
+
+func TestSingleton(t *testing.T) {
+	server, registries := newCluster(t, 2)
+	var singleton, local int32
+	for _, r := range registries {
+		_ = r.Add(Job{Name: "report", Spec: "@every 1m", Singleton: true, Handler: func(ctx context.Context) error {
+			atomic.AddInt32(&singleton, 1)
+			return nil
+		}})
+		_ = r.Add(Job{Name: "cleanup", Spec: "@every 1m", Handler: func(ctx context.Context) error {
+			atomic.AddInt32(&local, 1)
+			return nil
+		}})
+	}
+
+	for _, r := range registries {
+		tick(r, "report")
+		tick(r, "cleanup")
+	}
+	if singleton != 1 || local != 2 {
+		t.Fatalf("singleton job want 1 run and local job want 2 runs but get %d %d", singleton, local)
+	}
+
+	// 锁在任务结束后保留至多10秒，过期后下一周期可以再次执行
+	server.FastForward(singletonMaxHold)
+	tick(registries[1], "report")
+	if singleton != 2 {
+		t.Errorf("singleton job should run in next period but get %d runs", singleton)
+	}
+	if info, _ := registries[0].Get("report"); !info.Singleton {
+		t.Error("job info should report singleton")
+	}
+}
+
+func TestLeaderOnly(t *testing.T) {
+	_, registries := newCluster(t, 2)
+	deadline := time.Now().Add(3 * time.Second)
+	for !registries[0].IsLeader() && !registries[1].IsLeader() && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if registries[0].IsLeader() == registries[1].IsLeader() {
+		t.Fatalf("exactly one leader expected but get %v %v", registries[0].IsLeader(), registries[1].IsLeader())
+	}
+
+	var runs [2]int32
+	for i, r := range registries {
+		i := i
+		_ = r.Add(Job{Name: "rebuild", Spec: "@every 1m", LeaderOnly: true, Handler: func(ctx context.Context) error {
+			atomic.AddInt32(&runs[i], 1)
+			return nil
+		}})
+		tick(r, "rebuild")
+	}
+	if runs[0]+runs[1] != 1 || registries[0].IsLeader() != (runs[0] == 1) {
+		t.Errorf("leader only job should run on leader only but get %v", runs)
+	}
+
+	// 未启用集群协调时单实例视为主节点
+	if !newTestRegistry(t).IsLeader() {
+		t.Error("registry without locker should be leader")
+	}
+}
+
+// lostLocker 获取后可手动标记丢失的集群锁
+type lostLocker struct {
+	lost chan struct{}
+}
+
+func (l *lostLocker) TryLock(ctx context.Context, key string, ttl time.Duration) (Lease, error) {
+	return l, nil
+}
+
+func (l *lostLocker) Lost() <-chan struct{} {
+	return l.lost
+}
+
+func (l *lostLocker) Release(ctx context.Context, hold time.Duration) error {
+	return nil
+}
+
+func TestSingletonLockLost(t *testing.T) {
+	r := newTestRegistry(t)
+	locker := &lostLocker{lost: make(chan struct{})}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	r.UseLocker(ctx, locker)
+	started := make(chan struct{})
+	_ = r.Add(Job{Name: "report", Spec: "@every 1m", Singleton: true, Handler: func(ctx context.Context) error {
+		close(started)
+		<-ctx.Done()
+		return ctx.Err()
+	}})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		tick(r, "report")
+	}()
+	<-started
+	close(locker.lost)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("singleton job should be cancelled when lock lost")
+	}
+}
@@ -0,0 +1,9 @@
+package cronjobs
+
+// CronConfig 定时任务配置
+type CronConfig struct {
+	Enable       bool          `mapstructure:"enable" json:"enable" yaml:"enable"`                   // 是否开启定时任务
+	PersistTasks bool          `mapstructure:"persistTasks" json:"persistTasks" yaml:"persistTasks"` // 一次性任务是否持久化到数据库，需启用数据库
+	History      HistoryConfig `mapstructure:"history" json:"history" yaml:"history"`                // 执行记录
+	Jobs         []JobConfig   `mapstructure:"jobs" json:"jobs" yaml:"jobs"`                         // 配置定义的任务，处理函数通过 HandleJob 注册
+}
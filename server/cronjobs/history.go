@@ -0,0 +1,264 @@
+package cronjobs
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+/**
+* @Description: 任务执行记录，启用数据库时写入 cron_execution 表，否则保存在有界的内存环形缓冲区
+ */
+
+// 执行状态
+const (
+	StatusSuccess = "success" // 执行成功
+	StatusFailed  = "failed"  // 返回错误
+	StatusPanic   = "panic"   // 发生panic
+)
+
+// 执行记录默认配置
+const (
+	DefaultHistorySize      = 1000                // 内存记录最大条数
+	DefaultHistoryRetention = 30 * 24 * time.Hour // 记录保留时长
+	DefaultHistoryLimit     = 20                  // 查询默认条数
+	// PurgeJobName 清理过期执行记录的任务名称
+	PurgeJobName = "cron-history-purge"
+)
+
+// HistoryConfig 执行记录配置
+type HistoryConfig struct {
+	Size      int           `mapstructure:"size" json:"size" yaml:"size"`                // 未启用数据库时内存保留的最大条数，默认1000
+	Retention time.Duration `mapstructure:"retention" json:"retention" yaml:"retention"` // 记录保留时长，默认720h，小于0不清理
+}
+
+// withDefaults 填充默认值
+func (hc HistoryConfig) withDefaults() HistoryConfig {
+	if hc.Size <= 0 {
+		hc.Size = DefaultHistorySize
+	}
+	if hc.Retention == 0 {
+		hc.Retention = DefaultHistoryRetention
+	}
+	return hc
+}
+
+// Execution 一次任务执行记录
+type Execution struct {
+	ID        uint64        `gorm:"primarykey" json:"id"`
+	Job       string        `gorm:"size:128;index" json:"job"`
+	Status    string        `gorm:"size:16;index" json:"status"`
+	Attempt   int           `json:"attempt"` // 第几次尝试，从1开始
+	StartedAt time.Time     `gorm:"index" json:"startedAt"`
+	EndedAt   time.Time     `json:"endedAt"`
+	Duration  time.Duration `json:"duration"` // 执行耗时，纳秒
+	Error     string        `gorm:"type:text" json:"error,omitempty"`
+	Stack     string        `gorm:"type:text" json:"stack,omitempty"` // panic 堆栈
+}
+
+// TableName 执行记录表名
+func (Execution) TableName() string {
+	return "cron_execution"
+}
+
+// HistoryQuery 执行记录查询条件，零值字段不参与过滤
+type HistoryQuery struct {
+	Job    string
+	Status string
+	Since  time.Time // 开始时间不早于
+	Until  time.Time // 开始时间早于
+	Offset int
+	Limit  int // 默认20
+}
+
+// match 记录是否满足过滤条件
+func (q HistoryQuery) match(e *Execution) bool {
+	return (q.Job == "" || e.Job == q.Job) &&
+		(q.Status == "" || e.Status == q.Status) &&
+		(q.Since.IsZero() || !e.StartedAt.Before(q.Since)) &&
+		(q.Until.IsZero() || e.StartedAt.Before(q.Until))
+}
+
+// limit 查询条数
+func (q HistoryQuery) limit() int {
+	if q.Limit <= 0 {
+		return DefaultHistoryLimit
+	}
+	return q.Limit
+}
+
+// HistoryStore 执行记录存储
+type HistoryStore interface {
+	Record(ctx context.Context, exec *Execution) error
+	// Query 按开始时间倒序分页查询，同时返回满足条件的总数
+	Query(ctx context.Context, q HistoryQuery) (execs []Execution, total int64, err error)
+	// Purge 删除开始时间早于before的记录
+	Purge(ctx context.Context, before time.Time) (int64, error)
+}
+
+// MemoryHistory 内存环形缓冲区，写满后覆盖最早的记录
+type MemoryHistory struct {
+	mu     sync.RWMutex
+	buf    []Execution
+	next   int // 下一条写入位置
+	full   bool
+	nextID uint64
+}
+
+// NewMemoryHistory 创建最多保留size条记录的内存存储
+func NewMemoryHistory(size int) *MemoryHistory {
+	if size <= 0 {
+		size = DefaultHistorySize
+	}
+	return &MemoryHistory{buf: make([]Execution, size)}
+}
+
+func (mh *MemoryHistory) Record(_ context.Context, exec *Execution) error {
+	mh.mu.Lock()
+	defer mh.mu.Unlock()
+	mh.nextID++
+	exec.ID = mh.nextID
+	mh.buf[mh.next] = *exec
+	if mh.next = (mh.next + 1) % len(mh.buf); mh.next == 0 {
+		mh.full = true
+	}
+	return nil
+}
+
+// newest 从新到旧遍历记录，调用方需持有锁
+func (mh *MemoryHistory) newest(fn func(e *Execution)) {
+	n := mh.next
+	if mh.full {
+		n = len(mh.buf)
+	}
+	for i := 1; i <= n; i++ {
+		fn(&mh.buf[(mh.next-i+len(mh.buf))%len(mh.buf)])
+	}
+}
+
+func (mh *MemoryHistory) Query(_ context.Context, q HistoryQuery) ([]Execution, int64, error) {
+	mh.mu.RLock()
+	defer mh.mu.RUnlock()
+	var matched []Execution
+	mh.newest(func(e *Execution) {
+		if q.match(e) {
+			matched = append(matched, *e)
+		}
+	})
+	// 并发执行的任务写入顺序与开始时间可能不一致
+	sort.SliceStable(matched, func(i, j int) bool {
+		return matched[i].StartedAt.After(matched[j].StartedAt)
+	})
+
+	total := int64(len(matched))
+	if q.Offset >= len(matched) {
+		return []Execution{}, total, nil
+	}
+	matched = matched[q.Offset:]
+	if limit := q.limit(); len(matched) > limit {
+		matched = matched[:limit]
+	}
+	return matched, total, nil
+}
+
+func (mh *MemoryHistory) Purge(_ context.Context, before time.Time) (int64, error) {
+	mh.mu.Lock()
+	defer mh.mu.Unlock()
+	var kept []Execution
+	mh.newest(func(e *Execution) {
+		if !e.StartedAt.Before(before) {
+			kept = append(kept, *e)
+		}
+	})
+	purged := int64(mh.next - len(kept))
+	if mh.full {
+		purged = int64(len(mh.buf) - len(kept))
+	}
+
+	// 按从旧到新重新写入
+	buf := make([]Execution, len(mh.buf))
+	for i := range kept {
+		buf[i] = kept[len(kept)-1-i]
+	}
+	mh.buf, mh.next, mh.full = buf, len(kept)%len(buf), len(kept) == len(buf)
+	return purged, nil
+}
+
+// GormHistory 数据库存储
+type GormHistory struct {
+	db *gorm.DB
+}
+
+// NewGormHistory 创建数据库存储并自动建表
+func NewGormHistory(db *gorm.DB) (*GormHistory, error) {
+	if err := db.AutoMigrate(&Execution{}); err != nil {
+		return nil, err
+	}
+	return &GormHistory{db: db}, nil
+}
+
+func (gh *GormHistory) Record(ctx context.Context, exec *Execution) error {
+	return gh.db.WithContext(ctx).Create(exec).Error
+}
+
+func (gh *GormHistory) Query(ctx context.Context, q HistoryQuery) (execs []Execution, total int64, err error) {
+	tx := gh.db.WithContext(ctx).Model(&Execution{})
+	if q.Job != "" {
+		tx = tx.Where("job = ?", q.Job)
+	}
+	if q.Status != "" {
+		tx = tx.Where("status = ?", q.Status)
+	}
+	if !q.Since.IsZero() {
+		tx = tx.Where("started_at >= ?", q.Since)
+	}
+	if !q.Until.IsZero() {
+		tx = tx.Where("started_at < ?", q.Until)
+	}
+	if err = tx.Count(&total).Error; err != nil {
+		return
+	}
+	err = tx.Order("started_at DESC, id DESC").Offset(q.Offset).Limit(q.limit()).Find(&execs).Error
+	return
+}
+
+func (gh *GormHistory) Purge(ctx context.Context, before time.Time) (int64, error) {
+	tx := gh.db.WithContext(ctx).Where("started_at < ?", before).Delete(&Execution{})
+	return tx.RowsAffected, tx.Error
+}
+
+// ConfigureHistory 按配置设置执行记录存储，db不为空时写入数据库，否则保存在内存；
+// 保留时长大于0时注册过期记录清理任务
+func (r *Registry) ConfigureHistory(cfg HistoryConfig, db *gorm.DB) error {
+	cfg = cfg.withDefaults()
+	var store HistoryStore = NewMemoryHistory(cfg.Size)
+	if db != nil {
+		gh, err := NewGormHistory(db)
+		if err != nil {
+			return err
+		}
+		store = gh
+	}
+	r.UseHistory(store)
+	if cfg.Retention > 0 {
+		return r.Add(PurgeJob(store, cfg.Retention))
+	}
+	return nil
+}
+
+// PurgeJob 每小时清理一次超过保留时长的执行记录
+func PurgeJob(store HistoryStore, retention time.Duration) Job {
+	return Job{
+		Name:        PurgeJobName,
+		Spec:        "@every 1h",
+		Description: "清理过期的定时任务执行记录",
+		Singleton:   true,
+		Handler: func(ctx context.Context) error {
+			_, err := store.Purge(ctx, time.Now().Add(-retention))
+			return err
+		},
+	}
+}
@@ -0,0 +1,67 @@
+package cronjobs
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMemoryHistory(t *testing.T) {
+	ctx := context.Background()
+	mh := NewMemoryHistory(3)
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, status := range []string{StatusSuccess, StatusFailed, StatusSuccess, StatusPanic} {
+		_ = mh.Record(ctx, &Execution{Job: "report", Status: status, StartedAt: base.Add(time.Duration(i) * time.Hour)})
+	}
+
+	execs, total, _ := mh.Query(ctx, HistoryQuery{})
+	if total != 3 || execs[0].Status != StatusPanic || execs[2].Status != StatusFailed {
+		t.Fatalf("ring buffer should keep newest 3 records but get %d %+v", total, execs)
+	}
+	if execs, total, _ = mh.Query(ctx, HistoryQuery{Status: StatusSuccess}); total != 1 || execs[0].ID != 3 {
+		t.Errorf("status filter want record 3 but get %d %+v", total, execs)
+	}
+	if execs, total, _ = mh.Query(ctx, HistoryQuery{Offset: 1, Limit: 1}); total != 3 || len(execs) != 1 || execs[0].ID != 3 {
+		t.Errorf("pagination want record 3 but get %d %+v", total, execs)
+	}
+	if _, total, _ = mh.Query(ctx, HistoryQuery{Job: "missing"}); total != 0 {
+		t.Errorf("job filter want 0 but get %d", total)
+	}
+
+	if purged, _ := mh.Purge(ctx, base.Add(3*time.Hour)); purged != 2 {
+		t.Errorf("want 2 purged but get %d", purged)
+	}
+	_ = mh.Record(ctx, &Execution{Job: "report", Status: StatusSuccess, StartedAt: base.Add(4 * time.Hour)})
+	if execs, total, _ = mh.Query(ctx, HistoryQuery{}); total != 2 || execs[0].ID != 5 || execs[1].ID != 4 {
+		t.Errorf("want records 5 and 4 after purge but get %+v", execs)
+	}
+}
+
+func TestRunRecordsHistory(t *testing.T) {
+	r := newTestRegistry(t)
+	if err := r.ConfigureHistory(HistoryConfig{Size: 10, Retention: -1}, nil); err != nil {
+		t.Fatal(err)
+	}
+	boom := errors.New("boom")
+	_ = r.Add(Job{Name: "fail", Spec: "@yearly", Handler: func(ctx context.Context) error { return boom }})
+	_ = r.Add(Job{Name: "crash", Spec: "@yearly", Handler: func(ctx context.Context) error { panic("crash") }})
+
+	_ = r.RunNow(context.Background(), "fail")
+	if err := r.RunNow(context.Background(), "crash"); err == nil {
+		t.Error("panic should be returned as error")
+	}
+
+	execs, _, _ := r.History().Query(context.Background(), HistoryQuery{Status: StatusPanic})
+	if len(execs) != 1 || execs[0].Job != "crash" || !strings.Contains(execs[0].Stack, "history_test.go") {
+		t.Errorf("panic record should contain stack but get %+v", execs)
+	}
+	execs, _, _ = r.History().Query(context.Background(), HistoryQuery{Job: "fail"})
+	if len(execs) != 1 || execs[0].Status != StatusFailed || execs[0].Error != "boom" || execs[0].EndedAt.Before(execs[0].StartedAt) {
+		t.Errorf("unexpected failed record %+v", execs)
+	}
+	if _, err := r.Get(PurgeJobName); !errors.Is(err, ErrJobNotFound) {
+		t.Error("negative retention should not register purge job")
+	}
+}
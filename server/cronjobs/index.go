@@ -1,7 +1,6 @@
 package cronjobs
 
 import (
-	"fmt"
 	"sync"
 	"time"
 
@@ -16,26 +15,32 @@ var (
 // CronInstance cron single instance
 func CronInstance() *cron.Cron {
 	once.Do(func() {
-		// 初始化定时器调度对象
-		cc = cron.New(cron.WithSeconds())
+		// 初始化定时器调度对象，任务panic时恢复并输出到日志
+		cc = cron.New(cron.WithSeconds(), cron.WithLogger(Logger), cron.WithChain(cron.Recover(Logger)))
 	})
 
 	return cc
 }
 
-// DoOnce run job once time,this job will run after 2 second
+// DoOnce run job once after t seconds (default 2 seconds), no matter whether the cron instance is started.
+// A panic in job is recovered and logged.
+//
+// Deprecated: t is a number of seconds, so DoOnce(job, 5) runs after 5 seconds as before,
+// use DoAfter(job, 5*time.Second) instead.
 func DoOnce(job cron.Job, t ...time.Duration) error {
 	// default 2 seconds run in a cron job, can be custom
-	once := time.Now().Add(2 * time.Second)
+	delay := 2 * time.Second
 
 	// use custom seconds if t was set up
 	if len(t) == 1 {
-		once = time.Now().Add(t[0] * time.Second)
+		delay = t[0] * time.Second
 	}
+	return DoAfter(job, delay)
+}
 
-	onceSpec := fmt.Sprintf("%d %d %d %d %d %d", once.Second(), once.Minute(), once.Hour(), once.Day(), once.Month(), once.Weekday())
-	if _, err := CronInstance().AddJob(onceSpec, job); err != nil {
-		return err
-	}
+// DoAfter run job once after d, no matter whether the cron instance is started.
+// A panic in job is recovered and logged.
+func DoAfter(job cron.Job, d time.Duration) error {
+	time.AfterFunc(d, cron.NewChain(cron.Recover(Logger)).Then(job).Run)
 	return nil
 }
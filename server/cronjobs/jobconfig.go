@@ -0,0 +1,146 @@
+package cronjobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Domingor/go-blackbox/server/zaplog"
+)
+
+/**
+* @Description: 配置文件定义的任务，代码中按名称注册处理函数，启动及配置重新加载时绑定，修改执行时间无需重新编译
+ */
+
+// ErrHandlerNotFound 配置的任务名称没有注册处理函数
+var ErrHandlerNotFound = errors.New("cronjobs: job handler not registered")
+
+// JobConfig 配置文件中的任务定义
+type JobConfig struct {
+	Name         string        `mapstructure:"name" json:"name" yaml:"name"`                         // 任务名称，对应 HandleJob 注册的处理函数
+	Spec         string        `mapstructure:"spec" json:"spec" yaml:"spec"`                         // cron表达式，支持秒级
+	Timezone     string        `mapstructure:"timezone" json:"timezone" yaml:"timezone"`             // 时区，如 Asia/Shanghai，默认本地时区
+	Enable       bool          `mapstructure:"enable" json:"enable" yaml:"enable"`                   // 是否启用
+	Description  string        `mapstructure:"description" json:"description" yaml:"description"`    // 任务描述
+	Singleton    bool          `mapstructure:"singleton" json:"singleton" yaml:"singleton"`          // 多实例时每次调度只在一个实例执行
+	LeaderOnly   bool          `mapstructure:"leaderOnly" json:"leaderOnly" yaml:"leaderOnly"`       // 只在主节点执行
+	Overlap      string        `mapstructure:"overlap" json:"overlap" yaml:"overlap"`                // 上次执行未结束时的处理策略 skip/delay/allow，默认skip
+	Timeout      time.Duration `mapstructure:"timeout" json:"timeout" yaml:"timeout"`                // 单次执行超时时间，0不限制
+	Retries      int           `mapstructure:"retries" json:"retries" yaml:"retries"`                // 失败后重试次数
+	RetryBackoff time.Duration `mapstructure:"retryBackoff" json:"retryBackoff" yaml:"retryBackoff"` // 首次重试间隔，默认1s
+}
+
+// spec 带时区前缀的cron表达式
+func (jc JobConfig) spec() string {
+	if jc.Timezone == "" {
+		return jc.Spec
+	}
+	return "CRON_TZ=" + jc.Timezone + " " + jc.Spec
+}
+
+// validate 校验时区和表达式
+func (jc JobConfig) validate() error {
+	if jc.Timezone != "" {
+		if _, err := time.LoadLocation(jc.Timezone); err != nil {
+			return fmt.Errorf("cronjobs: invalid timezone %q of job %s: %w", jc.Timezone, jc.Name, err)
+		}
+	}
+	if err := Validate(jc.spec()); err != nil {
+		return fmt.Errorf("cronjobs: invalid spec %q of job %s: %w", jc.Spec, jc.Name, err)
+	}
+	return nil
+}
+
+// job 绑定处理函数
+func (jc JobConfig) job(handler func(ctx context.Context) error) Job {
+	return Job{
+		Name:         jc.Name,
+		Spec:         jc.spec(),
+		Description:  jc.Description,
+		Handler:      handler,
+		Singleton:    jc.Singleton,
+		LeaderOnly:   jc.LeaderOnly,
+		Overlap:      jc.Overlap,
+		Timeout:      jc.Timeout,
+		Retries:      jc.Retries,
+		RetryBackoff: jc.RetryBackoff,
+	}
+}
+
+// HandleJob 注册配置任务的处理函数
+func (r *Registry) HandleJob(name string, handler func(ctx context.Context) error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.jobHandlers[name] = handler
+}
+
+// ApplyConfig 按配置同步任务：新增启用的任务，删除停用或已移除的任务，定义有变化的任务重新调度。
+// 任何一项校验失败时不做任何修改
+func (r *Registry) ApplyConfig(jobs []JobConfig) error {
+	r.applyMu.Lock()
+	defer r.applyMu.Unlock()
+
+	wanted := make(map[string]JobConfig, len(jobs))
+	handlers := make(map[string]func(ctx context.Context) error, len(jobs))
+	var errs []error
+	r.mu.RLock()
+	for _, jc := range jobs {
+		if _, ok := wanted[jc.Name]; ok {
+			errs = append(errs, fmt.Errorf("%w: %s defined twice", ErrJobExists, jc.Name))
+			continue
+		}
+		wanted[jc.Name] = jc
+		if handlers[jc.Name] = r.jobHandlers[jc.Name]; handlers[jc.Name] == nil {
+			errs = append(errs, fmt.Errorf("%w: %s", ErrHandlerNotFound, jc.Name))
+			continue
+		}
+		if _, configured := r.configured[jc.Name]; !configured && r.jobs[jc.Name] != nil {
+			errs = append(errs, fmt.Errorf("%w: %s registered in code", ErrJobExists, jc.Name))
+			continue
+		}
+		if err := jc.validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	configured := make(map[string]JobConfig, len(r.configured))
+	for name, jc := range r.configured {
+		configured[name] = jc
+	}
+	r.mu.RUnlock()
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	for name, old := range configured {
+		if jc, ok := wanted[name]; ok && jc.Enable && jc == old {
+			continue
+		}
+		_ = r.Remove(name)
+		r.setConfigured(name, nil)
+		zaplog.SugaredLogger.Infof("cron job %s removed by config", name)
+	}
+	for name, jc := range wanted {
+		if old, ok := configured[name]; !jc.Enable || ok && jc == old {
+			continue
+		}
+		if err := r.Add(jc.job(handlers[name])); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		r.setConfigured(name, &jc)
+		zaplog.SugaredLogger.Infof("cron job %s scheduled by config: %s", name, jc.spec())
+	}
+	return errors.Join(errs...)
+}
+
+// setConfigured 记录由配置管理的任务，jc为空时删除
+func (r *Registry) setConfigured(name string, jc *JobConfig) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if jc == nil {
+		delete(r.configured, name)
+		return
+	}
+	r.configured[name] = *jc
+}
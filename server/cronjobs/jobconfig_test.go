@@ -0,0 +1,73 @@
+package cronjobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestApplyConfig(t *testing.T) {
+	r := newTestRegistry(t)
+	noop := func(ctx context.Context) error { return nil }
+	r.HandleJob("report", noop)
+	r.HandleJob("archive", noop)
+	r.HandleJob("manual", noop)
+	_ = r.Add(Job{Name: "manual", Spec: "@daily", Handler: noop})
+
+	// 任意一项无效时不做任何修改
+	err := r.ApplyConfig([]JobConfig{
+		{Name: "report", Spec: "0 0 8 * * *", Enable: true},
+		{Name: "unknown", Spec: "@daily", Enable: true},
+		{Name: "archive", Spec: "@daily", Timezone: "Mars/Olympus", Enable: true},
+		{Name: "manual", Spec: "@daily", Enable: true},
+	})
+	if !errors.Is(err, ErrHandlerNotFound) || !errors.Is(err, ErrJobExists) {
+		t.Errorf("want ErrHandlerNotFound and ErrJobExists but get %v", err)
+	}
+	if len(r.List()) != 1 {
+		t.Fatalf("invalid config should not change jobs but get %+v", r.List())
+	}
+
+	if err = r.ApplyConfig([]JobConfig{
+		{Name: "report", Spec: "0 0 8 * * *", Timezone: "Asia/Shanghai", Enable: true, Retries: 2},
+		{Name: "archive", Spec: "@daily"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	info, err := r.Get("report")
+	if err != nil || info.Spec != "CRON_TZ=Asia/Shanghai 0 0 8 * * *" {
+		t.Fatalf("report should be scheduled with timezone but get %+v %v", info, err)
+	}
+	if next := info.Next.UTC(); next.Hour() != 0 || next.Minute() != 0 {
+		t.Errorf("08:00 Asia/Shanghai should be 00:00 UTC but get %s", next)
+	}
+	if _, err = r.Get("archive"); !errors.Is(err, ErrJobNotFound) {
+		t.Error("disabled job should not be scheduled")
+	}
+
+	// 未修改的任务保持原状态，修改的任务重新调度，移除的任务停止
+	_ = r.Pause("report")
+	_ = r.ApplyConfig([]JobConfig{
+		{Name: "report", Spec: "0 0 8 * * *", Timezone: "Asia/Shanghai", Enable: true, Retries: 2},
+		{Name: "archive", Spec: "@daily", Enable: true},
+	})
+	if info, _ = r.Get("report"); !info.Paused {
+		t.Error("unchanged job should keep paused state")
+	}
+	_ = r.ApplyConfig([]JobConfig{
+		{Name: "report", Spec: "0 30 9 * * *", Enable: true},
+	})
+	if info, _ = r.Get("report"); info.Paused || info.Spec != "0 30 9 * * *" {
+		t.Errorf("changed job should be rescheduled but get %+v", info)
+	}
+	if _, err = r.Get("archive"); !errors.Is(err, ErrJobNotFound) {
+		t.Error("job removed from config should be removed")
+	}
+	if _, err = r.Get("manual"); err != nil {
+		t.Error("job registered in code should not be touched by config")
+	}
+	if next := r.List()[1].Next; next.IsZero() || next.After(time.Now().Add(24*time.Hour)) {
+		t.Errorf("unexpected next run %s", next)
+	}
+}
@@ -0,0 +1,69 @@
+package cronjobs
+
+import (
+	"time"
+
+	"github.com/Domingor/go-blackbox/server/zaplog"
+	"github.com/robfig/cron/v3"
+)
+
+/**
+* @Description: 任务执行中间件：panic恢复、重叠执行控制、超时和失败重试
+ */
+
+// 上次执行未结束时的处理策略
+const (
+	OverlapSkip  = "skip"  // 跳过本次调度，命名任务默认
+	OverlapDelay = "delay" // 等待上次执行结束后再执行
+	OverlapAllow = "allow" // 允许并发执行
+)
+
+// 失败重试默认配置
+const (
+	DefaultRetryBackoff    = time.Second
+	DefaultRetryMaxBackoff = time.Minute
+)
+
+// Logger 将cron调度器日志输出到zaplog
+var Logger cron.Logger = zapLogger{}
+
+type zapLogger struct{}
+
+func (zapLogger) Info(msg string, keysAndValues ...interface{}) {
+	zaplog.SugaredLogger.Debugw(msg, keysAndValues...)
+}
+
+func (zapLogger) Error(err error, msg string, keysAndValues ...interface{}) {
+	zaplog.SugaredLogger.Errorw(msg, append(keysAndValues, "error", err)...)
+}
+
+// overlapWrapper 按任务设置选择重叠执行控制
+func overlapWrapper(policy string) cron.JobWrapper {
+	switch policy {
+	case OverlapAllow:
+		return func(j cron.Job) cron.Job { return j }
+	case OverlapDelay:
+		return cron.DelayIfStillRunning(Logger)
+	default:
+		return cron.SkipIfStillRunning(Logger)
+	}
+}
+
+// retryBackoff 第n次重试前的等待时长，按指数增长，不超过1分钟和初始间隔中的较大值
+func (job Job) retryBackoff(n int) time.Duration {
+	backoff := job.RetryBackoff
+	if backoff <= 0 {
+		backoff = DefaultRetryBackoff
+	}
+	limit := DefaultRetryMaxBackoff
+	if backoff > limit {
+		limit = backoff
+	}
+	for i := 1; i < n && backoff < limit; i++ {
+		backoff *= 2
+	}
+	if backoff > limit {
+		backoff = limit
+	}
+	return backoff
+}
@@ -0,0 +1,130 @@
+package cronjobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetries(t *testing.T) {
+	r := newTestRegistry(t)
+	var calls int32
+	_ = r.Add(Job{Name: "flaky", Spec: "@yearly", Retries: 3, RetryBackoff: time.Millisecond, Handler: func(ctx context.Context) error {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			return errors.New("temporary")
+		}
+		return nil
+	}})
+	if err := r.RunNow(context.Background(), "flaky"); err != nil || calls != 3 {
+		t.Fatalf("want success on third attempt but get %v after %d calls", err, calls)
+	}
+	execs, _, _ := r.History().Query(context.Background(), HistoryQuery{Job: "flaky"})
+	if len(execs) != 3 || execs[0].Attempt != 3 || execs[0].Status != StatusSuccess || execs[2].Status != StatusFailed {
+		t.Errorf("each attempt should be recorded but get %+v", execs)
+	}
+
+	// ctx结束后不再重试
+	ctx, cancel := context.WithCancel(context.Background())
+	calls = 0
+	_ = r.Add(Job{Name: "broken", Spec: "@yearly", Retries: 5, RetryBackoff: time.Hour, Handler: func(ctx context.Context) error {
+		atomic.AddInt32(&calls, 1)
+		cancel()
+		return errors.New("broken")
+	}})
+	if err := r.RunNow(ctx, "broken"); err == nil || calls != 1 {
+		t.Errorf("cancelled ctx should stop retries but get %v after %d calls", err, calls)
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	job := Job{}
+	for n, want := range map[int]time.Duration{1: time.Second, 2: 2 * time.Second, 4: 8 * time.Second, 10: time.Minute} {
+		if got := job.retryBackoff(n); got != want {
+			t.Errorf("retry %d want %s but get %s", n, want, got)
+		}
+	}
+	if got := (Job{RetryBackoff: 5 * time.Minute}).retryBackoff(3); got != 5*time.Minute {
+		t.Errorf("backoff above default limit should be kept but get %s", got)
+	}
+}
+
+func TestTimeout(t *testing.T) {
+	r := newTestRegistry(t)
+	_ = r.Add(Job{Name: "slow", Spec: "@yearly", Timeout: 20 * time.Millisecond, Handler: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}})
+	if err := r.RunNow(context.Background(), "slow"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("want DeadlineExceeded but get %v", err)
+	}
+}
+
+func TestOverlap(t *testing.T) {
+	for policy, want := range map[string]int32{"": 1, OverlapSkip: 1, OverlapDelay: 2, OverlapAllow: 2} {
+		r := newTestRegistry(t)
+		var runs int32
+		release := make(chan struct{})
+		_ = r.Add(Job{Name: "sync", Spec: "@yearly", Overlap: policy, Handler: func(ctx context.Context) error {
+			if atomic.AddInt32(&runs, 1) == 1 {
+				<-release
+			}
+			return nil
+		}})
+		job := r.chain(r.jobs["sync"])
+
+		var wg sync.WaitGroup
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			job.Run()
+		}()
+		for atomic.LoadInt32(&runs) == 0 {
+			time.Sleep(time.Millisecond)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			job.Run()
+		}()
+		time.Sleep(20 * time.Millisecond)
+		close(release)
+		wg.Wait()
+		if runs != want {
+			t.Errorf("policy %q want %d runs but get %d", policy, want, runs)
+		}
+	}
+}
+
+func TestResumeKeepsOverlap(t *testing.T) {
+	r := newTestRegistry(t)
+	var runs int32
+	release := make(chan struct{})
+	_ = r.Add(Job{Name: "sync", Spec: "@yearly", Handler: func(ctx context.Context) error {
+		if atomic.AddInt32(&runs, 1) == 1 {
+			<-release
+		}
+		return nil
+	}})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		r.cron.Entry(r.jobs["sync"].entryID).Job.Run()
+	}()
+	for atomic.LoadInt32(&runs) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// 暂停恢复后上次执行仍未结束，本次调度应跳过
+	_ = r.Pause("sync")
+	_ = r.Resume("sync")
+	r.cron.Entry(r.jobs["sync"].entryID).Job.Run()
+	close(release)
+	<-done
+	if n := atomic.LoadInt32(&runs); n != 1 {
+		t.Errorf("resumed job should not overlap running execution but runs %d", n)
+	}
+}
@@ -0,0 +1,220 @@
+package cronjobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/Domingor/go-blackbox/server/zaplog"
+	"gorm.io/gorm"
+)
+
+/**
+* @Description: 一次性延时任务，按名称注册处理函数，执行后即从待执行列表删除；
+* 设置 TaskStore 后待执行任务持久化，重启后继续调度，多实例时通过删除记录争夺执行权，保证最多执行一次
+ */
+
+// TaskHandler 一次性任务处理函数，payload为创建任务时传入的参数
+type TaskHandler func(ctx context.Context, payload string) error
+
+// Task 待执行的一次性任务
+type Task struct {
+	ID        string    `gorm:"primarykey;size:32" json:"id"`
+	Name      string    `gorm:"size:128;index" json:"name"` // 处理函数名称
+	Payload   string    `gorm:"type:text" json:"payload"`
+	RunAt     time.Time `gorm:"index" json:"runAt"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// TableName 一次性任务表名
+func (Task) TableName() string {
+	return "cron_task"
+}
+
+// TaskStore 一次性任务持久化存储
+type TaskStore interface {
+	Save(ctx context.Context, task *Task) error
+	// Claim 删除任务，删除成功表示获得执行权，任务已被其他实例执行或取消时返回false
+	Claim(ctx context.Context, id string) (bool, error)
+	// List 全部待执行任务
+	List(ctx context.Context) ([]Task, error)
+}
+
+// GormTaskStore 数据库存储
+type GormTaskStore struct {
+	db *gorm.DB
+}
+
+// NewGormTaskStore 创建数据库存储并自动建表
+func NewGormTaskStore(db *gorm.DB) (*GormTaskStore, error) {
+	if err := db.AutoMigrate(&Task{}); err != nil {
+		return nil, err
+	}
+	return &GormTaskStore{db: db}, nil
+}
+
+func (gs *GormTaskStore) Save(ctx context.Context, task *Task) error {
+	return gs.db.WithContext(ctx).Create(task).Error
+}
+
+func (gs *GormTaskStore) Claim(ctx context.Context, id string) (bool, error) {
+	tx := gs.db.WithContext(ctx).Where("id = ?", id).Delete(&Task{})
+	return tx.RowsAffected == 1, tx.Error
+}
+
+func (gs *GormTaskStore) List(ctx context.Context) (tasks []Task, err error) {
+	err = gs.db.WithContext(ctx).Order("run_at").Find(&tasks).Error
+	return
+}
+
+// pendingTask 本实例已调度的任务
+type pendingTask struct {
+	Task
+	timer *time.Timer
+}
+
+// HandleTask 注册一次性任务处理函数
+func (r *Registry) HandleTask(name string, handler TaskHandler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.handlers[name] = handler
+}
+
+// UseTaskStore 设置持久化存储，并调度存储中的待执行任务，已过期的任务立即执行，
+// 处理函数未注册的任务保留在存储中
+func (r *Registry) UseTaskStore(ctx context.Context, store TaskStore) error {
+	tasks, err := store.List(ctx)
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.store = store
+	for _, task := range tasks {
+		if _, ok := r.handlers[task.Name]; !ok {
+			zaplog.SugaredLogger.Warnf("cron task %s skipped: handler %s not registered", task.ID, task.Name)
+			continue
+		}
+		if _, ok := r.tasks[task.ID]; !ok {
+			r.schedule(task)
+		}
+	}
+	return nil
+}
+
+// RunAt 在指定时间执行一次name对应的处理函数，返回任务ID，ctx用于持久化任务
+func (r *Registry) RunAt(ctx context.Context, at time.Time, name, payload string) (string, error) {
+	id, err := newTaskID()
+	if err != nil {
+		return "", err
+	}
+	task := Task{ID: id, Name: name, Payload: payload, RunAt: at, CreatedAt: time.Now()}
+
+	r.mu.RLock()
+	_, ok := r.handlers[name]
+	store := r.store
+	r.mu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("%w: task handler %s", ErrJobNotFound, name)
+	}
+	// 持久化在锁外进行，避免存储较慢时阻塞调度及其他操作
+	if store != nil {
+		if err = store.Save(ctx, &task); err != nil {
+			return "", err
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.schedule(task)
+	return id, nil
+}
+
+// RunAfter 在d时长后执行一次name对应的处理函数，返回任务ID，ctx用于持久化任务
+func (r *Registry) RunAfter(ctx context.Context, d time.Duration, name, payload string) (string, error) {
+	return r.RunAt(ctx, time.Now().Add(d), name, payload)
+}
+
+// Cancel 取消未执行的一次性任务
+func (r *Registry) Cancel(id string) error {
+	r.mu.Lock()
+	pt, ok := r.tasks[id]
+	if ok {
+		pt.timer.Stop()
+		delete(r.tasks, id)
+	}
+	store, ctx := r.store, r.ctx
+	r.mu.Unlock()
+
+	if store != nil {
+		claimed, err := store.Claim(ctx, id)
+		if err != nil || claimed {
+			return err
+		}
+	}
+	if !ok {
+		return fmt.Errorf("%w: task %s", ErrJobNotFound, id)
+	}
+	return nil
+}
+
+// Pending 按执行时间列出本实例已调度的一次性任务
+func (r *Registry) Pending() []Task {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	tasks := make([]Task, 0, len(r.tasks))
+	for _, pt := range r.tasks {
+		tasks = append(tasks, pt.Task)
+	}
+	sort.Slice(tasks, func(i, j int) bool {
+		return tasks[i].RunAt.Before(tasks[j].RunAt)
+	})
+	return tasks
+}
+
+// schedule 到期后执行任务，调用方需持有锁
+func (r *Registry) schedule(task Task) {
+	pt := &pendingTask{Task: task}
+	pt.timer = time.AfterFunc(time.Until(task.RunAt), func() { r.fire(pt) })
+	r.tasks[task.ID] = pt
+}
+
+// fire 执行到期任务，进程退出过程中不再执行，持久化的任务留待重启后执行
+func (r *Registry) fire(pt *pendingTask) {
+	r.mu.Lock()
+	ctx, store, handler := r.ctx, r.store, r.handlers[pt.Name]
+	if r.tasks[pt.ID] != pt || ctx.Err() != nil {
+		r.mu.Unlock()
+		return
+	}
+	delete(r.tasks, pt.ID)
+	r.mu.Unlock()
+
+	if store != nil {
+		claimed, err := store.Claim(ctx, pt.ID)
+		if err != nil {
+			zaplog.SugaredLogger.Errorf("cron task %s claim failed: %s", pt.ID, err)
+			return
+		}
+		if !claimed {
+			zaplog.SugaredLogger.Debugf("cron task %s skipped: claimed by another instance", pt.ID)
+			return
+		}
+	}
+	payload := pt.Payload
+	_ = r.run(ctx, &registeredJob{Job: Job{Name: pt.Name, Handler: func(ctx context.Context) error {
+		return handler(ctx, payload)
+	}}})
+}
+
+// newTaskID 随机任务ID
+func newTaskID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,189 @@
+package cronjobs
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// memoryTaskStore 多个注册表共享，模拟数据库
+type memoryTaskStore struct {
+	mu     sync.Mutex
+	tasks  map[string]Task
+	onSave func()
+}
+
+func (ms *memoryTaskStore) Save(_ context.Context, task *Task) error {
+	if ms.onSave != nil {
+		ms.onSave()
+	}
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.tasks[task.ID] = *task
+	return nil
+}
+
+func (ms *memoryTaskStore) Claim(_ context.Context, id string) (bool, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	_, ok := ms.tasks[id]
+	delete(ms.tasks, id)
+	return ok, nil
+}
+
+func (ms *memoryTaskStore) List(context.Context) ([]Task, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	tasks := make([]Task, 0, len(ms.tasks))
+	for _, task := range ms.tasks {
+		tasks = append(tasks, task)
+	}
+	return tasks, nil
+}
+
+func TestRunAfter(t *testing.T) {
+	ctx := context.Background()
+	r := newTestRegistry(t)
+	got := make(chan string, 2)
+	r.HandleTask("notify", func(ctx context.Context, payload string) error {
+		got <- payload
+		return nil
+	})
+
+	if _, err := r.RunAfter(ctx, time.Millisecond, "missing", ""); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("unregistered handler want ErrJobNotFound but get %v", err)
+	}
+	id, _ := r.RunAfter(ctx, time.Hour, "notify", "cancelled")
+	_, _ = r.RunAfter(ctx, 10*time.Millisecond, "notify", "user:1")
+	if pending := r.Pending(); len(pending) != 2 || pending[1].ID != id {
+		t.Fatalf("unexpected pending tasks %+v", pending)
+	}
+
+	select {
+	case payload := <-got:
+		if payload != "user:1" {
+			t.Errorf("want payload user:1 but get %s", payload)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("task should run")
+	}
+	if err := r.Cancel(id); err != nil {
+		t.Fatal(err)
+	}
+	if pending := r.Pending(); len(pending) != 0 {
+		t.Errorf("executed and cancelled tasks should be removed but get %+v", pending)
+	}
+	if err := r.Cancel(id); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("cancel twice want ErrJobNotFound but get %v", err)
+	}
+	// 处理函数返回后才写入执行记录
+	var total int64
+	for deadline := time.Now().Add(time.Second); total == 0 && time.Now().Before(deadline); time.Sleep(5 * time.Millisecond) {
+		_, total, _ = r.History().Query(context.Background(), HistoryQuery{Job: "notify"})
+	}
+	if total != 1 {
+		t.Errorf("task run should be recorded but get %d", total)
+	}
+}
+
+func TestTaskStore(t *testing.T) {
+	ctx := context.Background()
+	store := &memoryTaskStore{tasks: make(map[string]Task)}
+	var mu sync.Mutex
+	runs := make(map[string]int)
+	handler := func(ctx context.Context, payload string) error {
+		mu.Lock()
+		runs[payload]++
+		mu.Unlock()
+		return nil
+	}
+
+	// 重启前持久化的任务：一个已过期，一个处理函数未注册
+	_ = store.Save(context.Background(), &Task{ID: "overdue", Name: "notify", Payload: "overdue", RunAt: time.Now().Add(-time.Minute)})
+	_ = store.Save(context.Background(), &Task{ID: "orphan", Name: "unknown", RunAt: time.Now()})
+
+	registries := []*Registry{newTestRegistry(t), newTestRegistry(t)}
+	for _, r := range registries {
+		r.HandleTask("notify", handler)
+		if err := r.UseTaskStore(context.Background(), store); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, _ = registries[0].RunAfter(ctx, 20*time.Millisecond, "notify", "fresh")
+	// 另一实例重启后也加载了同一任务
+	_ = registries[1].UseTaskStore(context.Background(), store)
+
+	deadline := time.Now().Add(time.Second)
+	for {
+		mu.Lock()
+		done := runs["overdue"] > 0 && runs["fresh"] > 0
+		mu.Unlock()
+		if done || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	if runs["overdue"] != 1 || runs["fresh"] != 1 {
+		t.Errorf("each task should run exactly once but get %v", runs)
+	}
+	if tasks, _ := store.List(context.Background()); len(tasks) != 1 || tasks[0].ID != "orphan" {
+		t.Errorf("task without handler should stay in store but get %+v", tasks)
+	}
+}
+
+func TestRunAtSaveWithoutLock(t *testing.T) {
+	r := newTestRegistry(t)
+	r.HandleTask("notify", func(ctx context.Context, payload string) error { return nil })
+	store := &memoryTaskStore{tasks: make(map[string]Task)}
+	_ = r.UseTaskStore(context.Background(), store)
+
+	// 持久化期间注册表仍可访问
+	store.onSave = func() { _ = r.Pending() }
+	done := make(chan error, 1)
+	go func() {
+		_, err := r.RunAfter(context.Background(), time.Hour, "notify", "")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("task store should not be called while holding registry lock")
+	}
+	if len(r.Pending()) != 1 {
+		t.Error("saved task should be scheduled")
+	}
+}
+
+func TestDoOnce(t *testing.T) {
+	ran := make(chan struct{})
+	_ = DoAfter(cron.FuncJob(func() { close(ran) }), 10*time.Millisecond)
+	select {
+	case <-ran:
+	case <-time.After(time.Second):
+		t.Fatal("DoAfter job should run after delay")
+	}
+
+	// DoOnce 的参数单位为秒
+	start := time.Now()
+	done := make(chan time.Duration)
+	_ = DoOnce(cron.FuncJob(func() { done <- time.Since(start) }), 1)
+	select {
+	case elapsed := <-done:
+		if elapsed < time.Second {
+			t.Errorf("DoOnce should treat t as seconds but run after %s", elapsed)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("DoOnce job should run after 1 second")
+	}
+}
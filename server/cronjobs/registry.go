@@ -0,0 +1,414 @@
+package cronjobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Domingor/go-blackbox/server/zaplog"
+	"github.com/robfig/cron/v3"
+)
+
+var (
+	// ErrJobExists 任务名称已注册
+	ErrJobExists = errors.New("cronjobs: job already exists")
+	// ErrJobNotFound 任务未注册
+	ErrJobNotFound = errors.New("cronjobs: job not found")
+
+	// parser 与 CronInstance 一致，支持秒级表达式和 @every 等描述符
+	parser = cron.NewParser(cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+
+	registryOnce sync.Once
+	registry     *Registry
+)
+
+// Job 命名定时任务
+type Job struct {
+	Name         string                          // 任务名称，全局唯一
+	Spec         string                          // cron表达式，支持秒级
+	Description  string                          // 任务描述
+	Handler      func(ctx context.Context) error // 任务处理函数
+	Singleton    bool                            // 多实例部署时每次调度只在一个实例执行，需先调用 UseLocker
+	LeaderOnly   bool                            // 只在主节点执行，需先调用 UseLocker
+	Overlap      string                          // 上次执行未结束时的处理策略 skip/delay/allow，默认skip
+	Timeout      time.Duration                   // 单次执行超时时间，0不限制
+	Retries      int                             // 失败后重试次数
+	RetryBackoff time.Duration                   // 首次重试间隔，之后每次翻倍，默认1s
+}
+
+// JobInfo 任务运行状态
+type JobInfo struct {
+	Name        string    `json:"name"`
+	Spec        string    `json:"spec"`
+	Description string    `json:"description"`
+	Paused      bool      `json:"paused"`
+	Singleton   bool      `json:"singleton"`
+	LeaderOnly  bool      `json:"leaderOnly"`
+	Next        time.Time `json:"next"` // 下次执行时间，暂停时为零值
+	Prev        time.Time `json:"prev"` // 上次执行时间，未执行过为零值
+}
+
+// registeredJob 已注册的任务
+type registeredJob struct {
+	Job
+	entryID  cron.EntryID
+	schedule cron.Schedule
+	runner   cron.Job // 带重叠执行控制的任务，恢复调度时复用，保留上次执行的状态
+	paused   bool
+	prev     time.Time
+}
+
+// Registry 命名任务注册表，在 cron 调度器之上按名称管理任务
+type Registry struct {
+	mu      sync.RWMutex
+	cron    *cron.Cron
+	jobs    map[string]*registeredJob
+	ctx     context.Context // 任务执行时使用的上下文
+	locker  Locker          // 集群锁，为空时任务只在本实例协调
+	elector *elector
+	history HistoryStore // 执行记录
+
+	jobHandlers map[string]func(ctx context.Context) error // 配置任务处理函数
+	configured  map[string]JobConfig                       // 由配置管理的任务
+	applyMu     sync.Mutex
+
+	handlers map[string]TaskHandler // 一次性任务处理函数
+	tasks    map[string]*pendingTask
+	store    TaskStore // 一次性任务持久化存储，为空时只保存在内存
+}
+
+// NewRegistry 基于cron调度器创建任务注册表
+func NewRegistry(c *cron.Cron) *Registry {
+	return &Registry{
+		cron:        c,
+		jobs:        make(map[string]*registeredJob),
+		ctx:         context.Background(),
+		history:     NewMemoryHistory(DefaultHistorySize),
+		jobHandlers: make(map[string]func(ctx context.Context) error),
+		configured:  make(map[string]JobConfig),
+		handlers:    make(map[string]TaskHandler),
+		tasks:       make(map[string]*pendingTask),
+	}
+}
+
+// RegistryInstance 全局任务注册表，基于 CronInstance 调度
+func RegistryInstance() *Registry {
+	registryOnce.Do(func() {
+		registry = NewRegistry(CronInstance())
+	})
+	return registry
+}
+
+// Validate 校验cron表达式
+func Validate(spec string) error {
+	_, err := parser.Parse(spec)
+	return err
+}
+
+// Preview 计算cron表达式从from开始的后n次执行时间，n需大于0
+func Preview(spec string, from time.Time, n int) ([]time.Time, error) {
+	if n <= 0 {
+		return nil, fmt.Errorf("cronjobs: preview count must be positive, got %d", n)
+	}
+	schedule, err := parser.Parse(spec)
+	if err != nil {
+		return nil, err
+	}
+	runs := make([]time.Time, 0, n)
+	for next := from; len(runs) < n; {
+		if next = schedule.Next(next); next.IsZero() {
+			break
+		}
+		runs = append(runs, next)
+	}
+	return runs, nil
+}
+
+// Add 注册命名任务并开始调度
+func (r *Registry) Add(job Job) error {
+	if job.Name == "" || job.Handler == nil {
+		return fmt.Errorf("cronjobs: job name and handler are required")
+	}
+	schedule, err := parser.Parse(job.Spec)
+	if err != nil {
+		return fmt.Errorf("cronjobs: invalid spec %q of job %s: %w", job.Spec, job.Name, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.jobs[job.Name]; ok {
+		return fmt.Errorf("%w: %s", ErrJobExists, job.Name)
+	}
+	rj := &registeredJob{Job: job, schedule: schedule}
+	rj.runner = r.chain(rj)
+	rj.entryID = r.cron.Schedule(schedule, rj.runner)
+	r.jobs[job.Name] = rj
+	return nil
+}
+
+// Remove 停止调度并删除任务
+func (r *Registry) Remove(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rj, ok := r.jobs[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrJobNotFound, name)
+	}
+	if !rj.paused {
+		r.cron.Remove(rj.entryID)
+	}
+	delete(r.jobs, name)
+	return nil
+}
+
+// Pause 暂停调度，任务保留在注册表中
+func (r *Registry) Pause(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rj, ok := r.jobs[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrJobNotFound, name)
+	}
+	if !rj.paused {
+		r.cron.Remove(rj.entryID)
+		rj.paused = true
+	}
+	return nil
+}
+
+// Resume 恢复调度
+func (r *Registry) Resume(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rj, ok := r.jobs[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrJobNotFound, name)
+	}
+	if rj.paused {
+		rj.entryID = r.cron.Schedule(rj.schedule, rj.runner)
+		rj.paused = false
+	}
+	return nil
+}
+
+// RunNow 立即执行一次任务，不影响原有调度，暂停的任务也可以执行，不受重叠执行策略限制；
+// ctx 或 UseContext 设置的上下文结束时取消执行，进程退出时手动触发的任务同样收到取消信号
+func (r *Registry) RunNow(ctx context.Context, name string) error {
+	r.mu.RLock()
+	rj, ok := r.jobs[name]
+	base := r.ctx
+	r.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrJobNotFound, name)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-base.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return r.run(ctx, rj)
+}
+
+// Get 获取任务状态
+func (r *Registry) Get(name string) (JobInfo, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	rj, ok := r.jobs[name]
+	if !ok {
+		return JobInfo{}, fmt.Errorf("%w: %s", ErrJobNotFound, name)
+	}
+	return r.info(rj), nil
+}
+
+// List 按名称排序列出全部任务
+func (r *Registry) List() []JobInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	infos := make([]JobInfo, 0, len(r.jobs))
+	for _, rj := range r.jobs {
+		infos = append(infos, r.info(rj))
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+	return infos
+}
+
+// info 组装任务状态，调用方需持有锁
+func (r *Registry) info(rj *registeredJob) JobInfo {
+	info := JobInfo{
+		Name:        rj.Name,
+		Spec:        rj.Spec,
+		Description: rj.Description,
+		Paused:      rj.paused,
+		Singleton:   rj.Singleton,
+		LeaderOnly:  rj.LeaderOnly,
+		Prev:        rj.prev,
+	}
+	if !rj.paused {
+		// 调度器未启动时 Entry.Next 为零值，按当前时间推算
+		if info.Next = r.cron.Entry(rj.entryID).Next; info.Next.IsZero() {
+			info.Next = rj.schedule.Next(time.Now())
+		}
+	}
+	return info
+}
+
+// UseLocker 启用集群协调：Singleton 任务通过集群锁互斥执行，LeaderOnly 任务只在选举出的主节点执行，
+// 主节点竞选持续到ctx结束
+func (r *Registry) UseLocker(ctx context.Context, locker Locker) {
+	e := &elector{locker: locker}
+	r.mu.Lock()
+	r.locker, r.elector = locker, e
+	r.mu.Unlock()
+	go e.campaign(ctx)
+}
+
+// UseContext 设置任务执行的上下文，ctx结束时正在执行的任务收到取消信号
+func (r *Registry) UseContext(ctx context.Context) {
+	r.mu.Lock()
+	r.ctx = ctx
+	r.mu.Unlock()
+}
+
+// UseHistory 设置执行记录存储，默认保存在内存
+func (r *Registry) UseHistory(store HistoryStore) {
+	r.mu.Lock()
+	r.history = store
+	r.mu.Unlock()
+}
+
+// History 执行记录存储
+func (r *Registry) History() HistoryStore {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.history
+}
+
+// IsLeader 当前实例是否为主节点，未启用集群协调时单实例视为主节点
+func (r *Registry) IsLeader() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.elector == nil || r.elector.IsLeader()
+}
+
+// chain 转换为cron调度的任务，外层为重叠执行控制
+func (r *Registry) chain(rj *registeredJob) cron.Job {
+	return overlapWrapper(rj.Overlap)(r.wrap(rj))
+}
+
+// wrap 按任务设置进行集群协调，RunNow 不经过协调
+func (r *Registry) wrap(rj *registeredJob) cron.FuncJob {
+	return func() {
+		r.mu.RLock()
+		ctx, locker := r.ctx, r.locker
+		r.mu.RUnlock()
+		if locker == nil {
+			_ = r.run(ctx, rj)
+			return
+		}
+
+		if rj.LeaderOnly && !r.IsLeader() {
+			zaplog.SugaredLogger.Debugf("cron job %s skipped: not leader", rj.Name)
+			return
+		}
+		if !rj.Singleton {
+			_ = r.run(ctx, rj)
+			return
+		}
+
+		start := time.Now()
+		lease, err := locker.TryLock(ctx, singletonPrefix+rj.Name, singletonTTL)
+		if err != nil {
+			if errors.Is(err, ErrLocked) {
+				zaplog.SugaredLogger.Debugf("cron job %s skipped: running on another instance", rj.Name)
+			} else {
+				zaplog.SugaredLogger.Errorf("cron job %s skipped: lock failed: %s", rj.Name, err)
+			}
+			return
+		}
+		// 执行期间锁丢失（续期失败）时其他实例可能已开始执行，取消本次执行
+		jobCtx, cancel := context.WithCancel(ctx)
+		go func() {
+			select {
+			case <-lease.Lost():
+				zaplog.SugaredLogger.Warnf("cron job %s lock lost, cancel running", rj.Name)
+				cancel()
+			case <-jobCtx.Done():
+			}
+		}()
+		_ = r.run(jobCtx, rj)
+		cancel()
+		if err = lease.Release(context.Background(), singletonHold(rj.schedule, start)); err != nil {
+			zaplog.SugaredLogger.Warnf("cron job %s release lock failed: %s", rj.Name, err)
+		}
+	}
+}
+
+// singletonHold 任务结束后锁继续保留的时长：覆盖本周期剩余时间，
+// 使时钟略慢的实例在同一周期内无法再次获取锁，最长不超过半个周期
+func singletonHold(schedule cron.Schedule, start time.Time) time.Duration {
+	hold := schedule.Next(start).Sub(start) / 2
+	if hold > singletonMaxHold {
+		hold = singletonMaxHold
+	}
+	return hold - time.Since(start)
+}
+
+// run 执行任务，失败后按指数退避重试，ctx结束时停止重试
+func (r *Registry) run(ctx context.Context, rj *registeredJob) (err error) {
+	r.mu.Lock()
+	rj.prev = time.Now()
+	r.mu.Unlock()
+
+	for attempt := 1; ; attempt++ {
+		if err = r.attempt(ctx, rj, attempt); err == nil || attempt > rj.Retries {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(rj.retryBackoff(attempt)):
+		}
+	}
+}
+
+// attempt 执行一次任务并保存执行记录，panic 转换为错误返回
+func (r *Registry) attempt(ctx context.Context, rj *registeredJob, attempt int) (err error) {
+	exec := &Execution{Job: rj.Name, Attempt: attempt, StartedAt: time.Now()}
+	if rj.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, rj.Timeout)
+		defer cancel()
+	}
+
+	defer func() {
+		exec.Status = StatusSuccess
+		if p := recover(); p != nil {
+			exec.Status, exec.Stack = StatusPanic, string(debug.Stack())
+			err = fmt.Errorf("cronjobs: job %s panic: %v", rj.Name, p)
+		} else if err != nil {
+			exec.Status = StatusFailed
+		}
+		if err != nil {
+			exec.Error = err.Error()
+			zaplog.SugaredLogger.Errorf("cron job %s attempt %d failed: %s", rj.Name, attempt, err)
+		}
+		exec.EndedAt = time.Now()
+		exec.Duration = exec.EndedAt.Sub(exec.StartedAt)
+		// 任务ctx可能已取消，记录仍需保存
+		if recordErr := r.History().Record(context.Background(), exec); recordErr != nil {
+			zaplog.SugaredLogger.Warnf("cron job %s record history failed: %s", rj.Name, recordErr)
+		}
+	}()
+	return rj.Handler(ctx)
+}
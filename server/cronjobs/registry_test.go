@@ -0,0 +1,140 @@
+package cronjobs
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Domingor/go-blackbox/server/zaplog"
+	"github.com/robfig/cron/v3"
+	"go.uber.org/zap"
+)
+
+func init() {
+	zaplog.Logger = zap.NewNop()
+	zaplog.SugaredLogger = zaplog.Logger.Sugar()
+}
+
+func newTestRegistry(t *testing.T) *Registry {
+	c := cron.New(cron.WithSeconds())
+	c.Start()
+	t.Cleanup(func() { <-c.Stop().Done() })
+	return NewRegistry(c)
+}
+
+func TestRegistry(t *testing.T) {
+	r := newTestRegistry(t)
+	var runs int32
+	job := Job{Name: "report", Spec: "* * * * * *", Description: "日报", Handler: func(ctx context.Context) error {
+		atomic.AddInt32(&runs, 1)
+		return nil
+	}}
+	if err := r.Add(job); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Add(job); !errors.Is(err, ErrJobExists) {
+		t.Errorf("duplicate name want ErrJobExists but get %v", err)
+	}
+	if err := r.Add(Job{Name: "bad", Spec: "* *", Handler: job.Handler}); err == nil {
+		t.Error("invalid spec should fail")
+	}
+	_ = r.Add(Job{Name: "archive", Spec: "0 0 3 * * *", Handler: job.Handler})
+
+	list := r.List()
+	if len(list) != 2 || list[0].Name != "archive" || list[1].Description != "日报" || list[0].Next.IsZero() {
+		t.Fatalf("unexpected list %+v", list)
+	}
+
+	deadline := time.Now().Add(3 * time.Second)
+	for atomic.LoadInt32(&runs) == 0 && time.Now().Before(deadline) {
+		time.Sleep(50 * time.Millisecond)
+	}
+	if info, _ := r.Get("report"); atomic.LoadInt32(&runs) == 0 || info.Prev.IsZero() {
+		t.Fatalf("job should run on schedule, runs %d info %+v", runs, info)
+	}
+
+	if err := r.Pause("report"); err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := r.Get("report"); !info.Paused || !info.Next.IsZero() {
+		t.Errorf("paused job should have no next run but get %+v", info)
+	}
+	if err := r.Resume("report"); err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := r.Get("report"); info.Paused || info.Next.IsZero() {
+		t.Errorf("resumed job should be scheduled but get %+v", info)
+	}
+
+	if err := r.Remove("report"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := r.Get("report"); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("removed job want ErrJobNotFound but get %v", err)
+	}
+	for _, op := range []func(string) error{r.Pause, r.Resume, r.Remove} {
+		if err := op("missing"); !errors.Is(err, ErrJobNotFound) {
+			t.Errorf("want ErrJobNotFound but get %v", err)
+		}
+	}
+}
+
+func TestRunNow(t *testing.T) {
+	r := newTestRegistry(t)
+	boom := errors.New("boom")
+	_ = r.Add(Job{Name: "sync", Spec: "0 0 0 1 1 *", Handler: func(ctx context.Context) error {
+		return boom
+	}})
+	_ = r.Pause("sync")
+
+	if err := r.RunNow(context.Background(), "sync"); !errors.Is(err, boom) {
+		t.Errorf("RunNow should return handler error but get %v", err)
+	}
+	if info, _ := r.Get("sync"); info.Prev.IsZero() {
+		t.Error("RunNow should record prev run time")
+	}
+	if err := r.RunNow(context.Background(), "missing"); !errors.Is(err, ErrJobNotFound) {
+		t.Errorf("want ErrJobNotFound but get %v", err)
+	}
+
+	// 注册表上下文结束时取消手动触发的任务
+	ctx, cancel := context.WithCancel(context.Background())
+	r.UseContext(ctx)
+	_ = r.Add(Job{Name: "long", Spec: "0 0 0 1 1 *", Handler: func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}})
+	time.AfterFunc(10*time.Millisecond, cancel)
+	if err := r.RunNow(context.Background(), "long"); !errors.Is(err, context.Canceled) {
+		t.Errorf("RunNow should be cancelled with registry context but get %v", err)
+	}
+}
+
+func TestPreview(t *testing.T) {
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	runs, err := Preview("0 30 9 * * MON-FRI", from, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []time.Time{
+		time.Date(2024, 1, 1, 9, 30, 0, 0, time.UTC),
+		time.Date(2024, 1, 2, 9, 30, 0, 0, time.UTC),
+		time.Date(2024, 1, 3, 9, 30, 0, 0, time.UTC),
+	}
+	for i := range want {
+		if !runs[i].Equal(want[i]) {
+			t.Errorf("run %d want %s but get %s", i, want[i], runs[i])
+		}
+	}
+	if _, err = Preview("@daily", from, 0); err == nil {
+		t.Error("non-positive count should fail")
+	}
+	if err = Validate("@every 5m"); err != nil {
+		t.Errorf("descriptor should be valid but get %v", err)
+	}
+	if err = Validate("61 * * * * *"); err == nil {
+		t.Error("out of range second should be invalid")
+	}
+}
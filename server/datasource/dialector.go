@@ -0,0 +1,76 @@
+package datasource
+
+import (
+	"fmt"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+/**
+* @Description: 按配置的驱动生成gorm连接，支持 postgres、mysql 及纯Go实现的 sqlite（无需cgo，适合本地开发和测试）
+ */
+
+// 支持的数据库驱动
+const (
+	DriverPostgres = "postgres"
+	DriverMysql    = "mysql"
+	DriverSqlite   = "sqlite"
+)
+
+// driver 数据库驱动，默认postgres
+func (config *DataSourceConfig) driver() string {
+	if config.Driver == "" {
+		return DriverPostgres
+	}
+	return config.Driver
+}
+
+// port 数据库端口，未设置时使用驱动的默认端口
+func (config *DataSourceConfig) port() int {
+	if config.Port > 0 {
+		return config.Port
+	}
+	switch config.driver() {
+	case DriverMysql:
+		return 3306
+	case DriverPostgres:
+		return 5432
+	}
+	return 0
+}
+
+// dialector 指定节点的gorm连接，设置 DSN 时直接使用
+func (config *DataSourceConfig) dialector(host string, port int) (gorm.Dialector, error) {
+	if port <= 0 {
+		port = config.port()
+	}
+	switch config.driver() {
+	case DriverPostgres:
+		dsn := config.DSN
+		if dsn == "" {
+			dsn = fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%d sslmode=%s TimeZone=Asia/Shanghai",
+				host, config.UserName, config.Password, config.DbName, port, config.SSL)
+		}
+		return postgres.Open(dsn), nil
+	case DriverMysql:
+		dsn := config.DSN
+		if dsn == "" {
+			dsn = fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=True&loc=Local",
+				config.UserName, config.Password, host, port, config.DbName)
+		}
+		return mysql.Open(dsn), nil
+	case DriverSqlite:
+		dsn := config.DSN
+		if dsn == "" {
+			dsn = config.DbName
+		}
+		if dsn == "" {
+			return nil, fmt.Errorf("datasource: sqlite requires dbName or dsn as database file")
+		}
+		return sqlite.Open(dsn), nil
+	}
+	return nil, fmt.Errorf("datasource: unsupported driver %s", config.Driver)
+}
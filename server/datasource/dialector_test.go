@@ -0,0 +1,74 @@
+package datasource
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDialector(t *testing.T) {
+	tests := []struct {
+		config *DataSourceConfig
+		name   string
+		err    string
+	}{
+		{config: &DataSourceConfig{Host: "localhost"}, name: DriverPostgres},
+		{config: &DataSourceConfig{Driver: DriverMysql, Host: "localhost"}, name: DriverMysql},
+		{config: &DataSourceConfig{Driver: DriverSqlite, DbName: "app.db"}, name: DriverSqlite},
+		{config: &DataSourceConfig{Driver: DriverSqlite}, err: "requires dbName"},
+		{config: &DataSourceConfig{Driver: "oracle"}, err: "unsupported driver"},
+	}
+	for _, tt := range tests {
+		dialector, err := tt.config.dialector(tt.config.Host, tt.config.Port)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("driver %s want error %q but get %v", tt.config.Driver, tt.err, err)
+			}
+			continue
+		}
+		if err != nil || dialector.Name() != tt.name {
+			t.Errorf("driver %s want dialector %s but get %v %v", tt.config.Driver, tt.name, dialector, err)
+		}
+	}
+
+	if port := (&DataSourceConfig{Driver: DriverMysql}).port(); port != 3306 {
+		t.Errorf("mysql default port should be 3306 but get %d", port)
+	}
+	if port := (&DataSourceConfig{}).port(); port != 5432 {
+		t.Errorf("postgres default port should be 5432 but get %d", port)
+	}
+}
+
+func TestOpenSqlite(t *testing.T) {
+	resetDataSources(t)
+	config := &DataSourceConfig{Driver: DriverSqlite, DbName: filepath.Join(t.TempDir(), "app.db"), AliasName: "local"}
+	db, err := Open(config, &hero{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.Create(&hero{Name: "Homelander"}).Error; err != nil {
+		t.Fatal(err)
+	}
+	var h hero
+	if err = db.Where("name = ?", "Homelander").First(&h).Error; err != nil || h.ID == 0 {
+		t.Fatalf("query created record failed %+v %v", h, err)
+	}
+	if err = Ping(context.Background(), "local"); err != nil {
+		t.Error(err)
+	}
+	if err = Close("local"); err != nil {
+		t.Fatal(err)
+	}
+
+	config.Replicas = []string{"replica:5432"}
+	if _, err = Open(config); err == nil {
+		t.Error("sqlite should not support replicas")
+	}
+
+	// DSN 中的地址无法替换为副本地址
+	dsnConfig := &DataSourceConfig{DSN: "host=primary user=app dbname=app", Replicas: []string{"replica:5432"}}
+	if _, err = Open(dsnConfig); err == nil || !strings.Contains(err.Error(), "dsn") {
+		t.Errorf("replicas with dsn should fail but get %v", err)
+	}
+}
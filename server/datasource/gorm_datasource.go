@@ -0,0 +1,360 @@
+package datasource
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"github.com/Domingor/go-blackbox/apputils/assert"
+	"github.com/Domingor/go-blackbox/server/zaplog"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+/**
+* @Author: Connor
+* @Date:   23.2.23 10:04
+* @Description:
+ */
+
+// DefaultAlias 未设置 AliasName 时使用的数据源别名
+const DefaultAlias = "default"
+
+// 连接池默认配置
+const (
+	DefaultConnMaxLifetime = time.Hour
+	DefaultPingTimeout     = 3 * time.Second
+)
+
+var (
+	mu      sync.RWMutex
+	dbs     = make(map[string]*gorm.DB) // 按别名注册的数据源
+	aliases []string                    // 注册顺序
+)
+
+// PostgresConfig 兼容旧版本的配置名称
+type PostgresConfig = DataSourceConfig
+
+// DataSourceConfig 数据源配置，按 Driver 选择数据库驱动
+type DataSourceConfig struct {
+	Driver          string        `mapstructure:"driver" json:"driver" yaml:"driver"` // 数据库驱动 postgres/mysql/sqlite，默认postgres
+	DSN             string        `mapstructure:"dsn" json:"dsn" yaml:"dsn"`          // 完整连接串，设置后忽略host、port等连接字段，不能与replicas同时使用
+	UserName        string        `mapstructure:"user" json:"user" yaml:"user"`
+	Password        string        `mapstructure:"password" json:"password" yaml:"password"`
+	Host            string        `mapstructure:"host" json:"host" yaml:"host"`
+	Port            int           `mapstructure:"port" json:"port" yaml:"port"`
+	DbName          string        `mapstructure:"dbName" json:"dbName" yaml:"dbName"` // 数据库名，sqlite为数据库文件路径
+	InitDb          bool          `mapstructure:"initDb" json:"initDb" yaml:"initDb"`
+	AliasName       string        `mapstructure:"aliasName" json:"aliasName" yaml:"aliasName"`                   // 数据源别名，默认default
+	SSL             string        `mapstructure:"ssl" json:"ssl" yaml:"ssl"`                                     // postgres sslmode require/verify-full/verify-ca/disable
+	MaxIdleConns    int           `mapstructure:"maxIdleConns" json:"maxIdleConns" yaml:"maxIdleConns"`          // 最大闲置连接数
+	MaxOpenConns    int           `mapstructure:"maxOpenConns" json:"maxOpenConns" yaml:"maxOpenConns"`          // 最大连接数
+	ConnMaxLifetime time.Duration `mapstructure:"connMaxLifetime" json:"connMaxLifetime" yaml:"connMaxLifetime"` // 连接可复用的最大时间，默认1h
+	ConnMaxIdleTime time.Duration `mapstructure:"connMaxIdleTime" json:"connMaxIdleTime" yaml:"connMaxIdleTime"` // 连接最大空闲时间，0不限制
+
+	Log LogConfig `mapstructure:"log" json:"log" yaml:"log"` // SQL日志
+
+	SchemaPolicy   string `mapstructure:"schemaPolicy" json:"schemaPolicy" yaml:"schemaPolicy"`       // 表结构与model不一致时的处理策略 auto/log/refuse，默认auto
+	SchemaDiffFile string `mapstructure:"schemaDiffFile" json:"schemaDiffFile" yaml:"schemaDiffFile"` // 存在差异时写入DDL的SQL文件路径
+
+	Replicas             []string      `mapstructure:"replicas" json:"replicas" yaml:"replicas"`                                     // 只读副本地址 host:port，账号、库名与主库一致，需使用host、port配置主库
+	ReplicaPolicy        string        `mapstructure:"replicaPolicy" json:"replicaPolicy" yaml:"replicaPolicy"`                      // 副本选择策略 random/roundRobin，默认random
+	ReplicaCheckInterval time.Duration `mapstructure:"replicaCheckInterval" json:"replicaCheckInterval" yaml:"replicaCheckInterval"` // 副本健康检查间隔，默认10s
+}
+
+// Alias 数据源别名
+func (config *DataSourceConfig) Alias() string {
+	if config.AliasName == "" {
+		return DefaultAlias
+	}
+	return config.AliasName
+}
+
+// GormInit 初始化数据库连接信息、初始化model表信息，按 AliasName 注册数据源
+func GormInit(config *DataSourceConfig, models []interface{}) (err error) {
+	_, err = Open(config, models...)
+	return
+}
+
+// GetDbInstance 获取默认数据源，多个协程在使用公用db调用其他方法时，会从连接池中获取连接
+func GetDbInstance() (*gorm.DB, error) {
+	return GetDb()
+}
+
+// Open 打开数据源、初始化model表并按别名注册，别名已注册时返回错误
+func Open(config *DataSourceConfig, models ...interface{}) (*gorm.DB, error) {
+	if config == nil {
+		return nil, fmt.Errorf("datasource: config is nil")
+	}
+	alias := config.Alias()
+	if _, err := GetDb(alias); err == nil {
+		return nil, fmt.Errorf("datasource: alias %s already registered", alias)
+	}
+
+	zaplog.SugaredLogger.Infof("db %s (%s) starting initializing...", alias, config.driver())
+	db, err := gormOpen(config, models)
+	if err != nil {
+		return nil, err
+	}
+	if err = register(alias, db); err != nil {
+		_ = closeDb(db)
+		return nil, err
+	}
+	return db, nil
+}
+
+// register 按别名注册数据源
+func register(alias string, db *gorm.DB) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := dbs[alias]; ok {
+		return fmt.Errorf("datasource: alias %s already registered", alias)
+	}
+	dbs[alias] = db
+	aliases = append(aliases, alias)
+	return nil
+}
+
+// GetDb 按别名获取数据源，不传别名时返回 default 数据源，未注册 default 时返回最先注册的数据源
+func GetDb(alias ...string) (*gorm.DB, error) {
+	mu.RLock()
+	defer mu.RUnlock()
+	name := DefaultAlias
+	if len(alias) > 0 && alias[0] != "" {
+		name = alias[0]
+	} else if _, ok := dbs[name]; !ok && len(aliases) > 0 {
+		name = aliases[0]
+	}
+	db, ok := dbs[name]
+	if !ok {
+		return nil, fmt.Errorf("datasource: alias %s not registered", name)
+	}
+	return db, nil
+}
+
+// Aliases 按注册顺序返回全部数据源别名
+func Aliases() []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return append([]string(nil), aliases...)
+}
+
+// Ping 检查数据源连接是否可用
+func Ping(ctx context.Context, alias string) error {
+	db, err := GetDb(alias)
+	if err != nil {
+		return err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, DefaultPingTimeout)
+	defer cancel()
+	return sqlDB.PingContext(ctx)
+}
+
+// HealthCheck 检查全部数据源，返回每个别名的检查结果，nil表示正常
+func HealthCheck(ctx context.Context) map[string]error {
+	result := make(map[string]error)
+	for _, alias := range Aliases() {
+		result[alias] = Ping(ctx, alias)
+	}
+	return result
+}
+
+// Close 关闭并注销数据源
+func Close(alias string) error {
+	mu.Lock()
+	db, ok := dbs[alias]
+	if ok {
+		delete(dbs, alias)
+		for i, name := range aliases {
+			if name == alias {
+				aliases = append(aliases[:i], aliases[i+1:]...)
+				break
+			}
+		}
+	}
+	mu.Unlock()
+	if !ok {
+		return fmt.Errorf("datasource: alias %s not registered", alias)
+	}
+	return closeDb(db)
+}
+
+// closeDb 关闭数据库连接及读写分离的副本连接
+func closeDb(db *gorm.DB) error {
+	if rw, ok := db.Config.Plugins[(&ReadWriteSplit{}).Name()].(*ReadWriteSplit); ok {
+		_ = rw.Close()
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.Close()
+}
+
+// 初始化数据库连接
+func gormOpen(config *DataSourceConfig, tables []interface{}) (_db *gorm.DB, err error) {
+	// 副本按主库的连接字段替换host、port生成连接，DSN 中的地址无法替换
+	if len(config.Replicas) > 0 && config.DSN != "" {
+		return nil, fmt.Errorf("datasource: replicas cannot be used with dsn, configure host and port instead")
+	}
+	// SQL日志输出到zaplog
+	newLogger, err := NewZapLogger(config.Log)
+	if err != nil {
+		return
+	}
+	// 按驱动生成连接
+	dialector, err := config.dialector(config.Host, config.Port)
+	if err != nil {
+		return
+	}
+
+	// 表名规则
+	namingStrategy := schema.NamingStrategy{
+		//TablePrefix:   "", // table name prefix, table for `User` would be `t_users`
+		SingularTable: true, // use singular table name, table for `User` would be `user` with this option enabled
+		//NoLowerCase:   true,                              // skip the snake_casing of names
+		//NameReplacer:  strings.NewReplacer("CID", "Cid"), // use name replacer to change struct/field name before convert it to db name
+	}
+
+	// 打开数据库会话
+	db, err := gorm.Open(dialector, &gorm.Config{Logger: newLogger, NamingStrategy: namingStrategy})
+	if err != nil {
+		zaplog.SugaredLogger.Debugf("open datasource failed %v", err)
+		return
+	}
+	// 后续初始化失败时关闭已打开的连接
+	defer func() {
+		if err != nil {
+			_ = closeDb(db)
+		}
+	}()
+	_db = db
+
+	// 注册查询缓存插件，非默认数据源按别名隔离缓存
+	if queryCacher != nil {
+		plugin := NewQueryCache(queryCacher)
+		if alias := config.Alias(); alias != DefaultAlias {
+			plugin.WithAlias(alias)
+		}
+		if err = _db.Use(plugin); err != nil {
+			zaplog.SugaredLogger.Debugf("register query cache failed %v", err)
+			return nil, err
+		}
+	}
+
+	if err = setup(_db, config, tables); err != nil {
+		return nil, err
+	}
+
+	// 读写分离，在建表之后注册，避免迁移时读取副本上的旧表结构
+	if len(config.Replicas) > 0 {
+		if err = useReplicas(_db, config); err != nil {
+			zaplog.SugaredLogger.Debugf("open replicas failed %v", err)
+			return nil, err
+		}
+	}
+	return
+}
+
+// useReplicas 连接只读副本并注册读写分离插件，副本连接池参数与主库一致，失败时关闭已打开的副本连接
+func useReplicas(_db *gorm.DB, config *DataSourceConfig) (err error) {
+	if config.driver() == DriverSqlite {
+		return fmt.Errorf("datasource: replicas are not supported by %s", DriverSqlite)
+	}
+	replicas := make([]Replica, 0, len(config.Replicas))
+	defer func() {
+		if err != nil {
+			for _, replica := range replicas {
+				_ = replica.DB.Close()
+			}
+		}
+	}()
+	for _, addr := range config.Replicas {
+		sqlDB, err := openReplica(config, addr)
+		if err != nil {
+			return err
+		}
+		replicas = append(replicas, Replica{Name: addr, DB: sqlDB})
+	}
+	return _db.Use(NewReadWriteSplit(config.ReplicaPolicy, config.ReplicaCheckInterval, replicas...))
+}
+
+// openReplica 连接只读副本，未指定端口时使用主库端口
+func openReplica(config *DataSourceConfig, addr string) (*sql.DB, error) {
+	host, port := addr, config.port()
+	if h, p, err := net.SplitHostPort(addr); err == nil {
+		if port, err = strconv.Atoi(p); err != nil {
+			return nil, fmt.Errorf("datasource: invalid replica address %s", addr)
+		}
+		host = h
+	}
+	dialector, err := config.dialector(host, port)
+	if err != nil {
+		return nil, err
+	}
+	replicaDb, err := gorm.Open(dialector, &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		return nil, err
+	}
+	sqlDB, err := replicaDb.DB()
+	if err != nil {
+		return nil, err
+	}
+	setPool(sqlDB, config)
+	return sqlDB, nil
+}
+
+// MigrateModels 按 SchemaPolicy 初始化已打开数据源的model表，在主库执行，忽略nil模型；
+// 同时使用版本化迁移时，应在迁移之后调用，避免 AutoMigrate 先于迁移修改表结构
+func MigrateModels(_db *gorm.DB, config *DataSourceConfig, tables ...interface{}) error {
+	// 过滤 nil结构体
+	models := make([]interface{}, 0, len(tables))
+	for _, item := range tables {
+		if !assert.IsNilFixed(item) {
+			models = append(models, item)
+		}
+	}
+	if len(models) == 0 {
+		return nil
+	}
+	if err := migrateModels(_db.WithContext(UsePrimary(context.Background())), config, models); err != nil {
+		zaplog.SugaredLogger.Debugf("AutoMigrate tables failed %v", err)
+		return err
+	}
+	return nil
+}
+
+// setup 初始化model表、设置连接池参数
+func setup(_db *gorm.DB, config *DataSourceConfig, tables []interface{}) (err error) {
+	// 按策略自动创建、更新表
+	if err = MigrateModels(_db, config, tables...); err != nil {
+		return err
+	}
+
+	sqlDB, err := _db.DB() //设置数据库连接池参数
+	if err != nil {
+		return err
+	}
+	setPool(sqlDB, config)
+	return
+}
+
+// setPool 设置连接池参数
+func setPool(sqlDB *sql.DB, config *DataSourceConfig) {
+	lifetime := config.ConnMaxLifetime
+	if lifetime <= 0 {
+		lifetime = DefaultConnMaxLifetime
+	}
+	sqlDB.SetMaxOpenConns(config.MaxOpenConns)       // 设置数据库连接池最大连接数
+	sqlDB.SetMaxIdleConns(config.MaxIdleConns)       // 连接池最大允许的空闲连接数，如果没有sql任务需要执行的连接数大于20，超过的连接会被连接池关闭
+	sqlDB.SetConnMaxLifetime(lifetime)               // SetConnMaxLifetime 设置了连接可复用的最大时间。
+	sqlDB.SetConnMaxIdleTime(config.ConnMaxIdleTime) // 连接最大空闲时间
+}
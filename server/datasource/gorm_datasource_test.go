@@ -0,0 +1,102 @@
+package datasource
+
+import (
+	"context"
+	"database/sql"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Domingor/go-blackbox/server/cache"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// newFakeDb 基于假驱动打开数据源
+func newFakeDb(t *testing.T) *gorm.DB {
+	conn, err := sql.Open("fakedb", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: conn, WithoutReturning: true}), &gorm.Config{
+		Logger:                 logger.Discard,
+		SkipDefaultTransaction: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// resetDataSources 清空已注册的数据源
+func resetDataSources(t *testing.T) {
+	reset := func() {
+		mu.Lock()
+		dbs, aliases = make(map[string]*gorm.DB), nil
+		mu.Unlock()
+	}
+	reset()
+	t.Cleanup(reset)
+}
+
+func TestNamedDataSources(t *testing.T) {
+	resetDataSources(t)
+	if _, err := GetDb(); err == nil {
+		t.Error("no datasource registered should fail")
+	}
+
+	report, main := newFakeDb(t), newFakeDb(t)
+	_ = register("report", report)
+	if db, _ := GetDb(); db != report {
+		t.Error("first registered datasource should be default when default alias is missing")
+	}
+	_ = register((&PostgresConfig{}).Alias(), main)
+	if err := register(DefaultAlias, main); err == nil {
+		t.Error("duplicate alias should fail")
+	}
+
+	if db, _ := GetDb(); db != main {
+		t.Error("GetDb without alias should return default datasource")
+	}
+	if db, _ := GetDb("report"); db != report {
+		t.Error("GetDb should return datasource by alias")
+	}
+	if _, err := GetDb("missing"); err == nil {
+		t.Error("unknown alias should fail")
+	}
+	if got := Aliases(); len(got) != 2 || got[0] != "report" || got[1] != DefaultAlias {
+		t.Errorf("unexpected aliases %v", got)
+	}
+
+	for alias, err := range HealthCheck(context.Background()) {
+		if err != nil {
+			t.Errorf("datasource %s should be healthy but get %v", alias, err)
+		}
+	}
+	if err := Close("report"); err != nil {
+		t.Fatal(err)
+	}
+	if err := Ping(context.Background(), "report"); err == nil {
+		t.Error("closed datasource should be unregistered")
+	}
+}
+
+func TestQueryCacheAlias(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	shared := cache.NewMemoryCache(ctx)
+	main, report := newFakeDb(t), newFakeDb(t)
+	_ = main.Use(NewQueryCache(shared))
+	_ = report.Use(NewQueryCache(shared).WithAlias("report"))
+
+	atomic.StoreInt32(&fake.queries, 0)
+	for _, db := range []*gorm.DB{main, report, main, report} {
+		var heroes []hero
+		db.Scopes(Cached(time.Minute)).Find(&heroes)
+	}
+	if n := atomic.LoadInt32(&fake.queries); n != 2 {
+		t.Errorf("same table in different datasources should be cached separately, queries %d", n)
+	}
+}
@@ -0,0 +1,149 @@
+package datasource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Domingor/go-blackbox/server/zaplog"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+/**
+* @Description: gorm日志输出到zaplog，记录执行时长、影响行数及ctx中的请求ID，支持慢查询阈值和SQL参数脱敏
+ */
+
+// DefaultSlowThreshold 慢查询默认阈值
+const DefaultSlowThreshold = time.Second
+
+// packageDir 本包目录，查找SQL调用位置时跳过
+var packageDir = func() string {
+	_, file, _, _ := runtime.Caller(0)
+	return filepath.Dir(file)
+}()
+
+// LogConfig SQL日志配置
+type LogConfig struct {
+	Level         string        `mapstructure:"level" json:"level" yaml:"level"`                         // silent/error/warn/info，默认warn，info输出全部SQL
+	SlowThreshold time.Duration `mapstructure:"slowThreshold" json:"slowThreshold" yaml:"slowThreshold"` // 慢查询阈值，默认1s，负数不记录慢查询
+	Redact        bool          `mapstructure:"redact" json:"redact" yaml:"redact"`                      // 参数脱敏，日志中的SQL保留占位符不输出参数值
+}
+
+// ZapLogger 基于zaplog的gorm日志
+type ZapLogger struct {
+	level         logger.LogLevel
+	slowThreshold time.Duration
+	redact        bool
+}
+
+// NewZapLogger 创建gorm日志，通过 gorm.Config.Logger 使用
+func NewZapLogger(config LogConfig) (*ZapLogger, error) {
+	level, err := parseLogLevel(config.Level)
+	if err != nil {
+		return nil, err
+	}
+	threshold := config.SlowThreshold
+	if threshold == 0 {
+		threshold = DefaultSlowThreshold
+	}
+	return &ZapLogger{level: level, slowThreshold: threshold, redact: config.Redact}, nil
+}
+
+// parseLogLevel 日志级别名称转换为gorm日志级别
+func parseLogLevel(level string) (logger.LogLevel, error) {
+	switch level {
+	case "silent":
+		return logger.Silent, nil
+	case "error":
+		return logger.Error, nil
+	case "", "warn":
+		return logger.Warn, nil
+	case "info":
+		return logger.Info, nil
+	}
+	return 0, fmt.Errorf("datasource: unknown log level %s", level)
+}
+
+// LogMode 修改日志级别，返回新的日志对象
+func (l *ZapLogger) LogMode(level logger.LogLevel) logger.Interface {
+	newLogger := *l
+	newLogger.level = level
+	return &newLogger
+}
+
+func (l *ZapLogger) Info(ctx context.Context, msg string, data ...interface{}) {
+	if l.level >= logger.Info {
+		l.log(ctx).Info(fmt.Sprintf(msg, data...))
+	}
+}
+
+func (l *ZapLogger) Warn(ctx context.Context, msg string, data ...interface{}) {
+	if l.level >= logger.Warn {
+		l.log(ctx).Warn(fmt.Sprintf(msg, data...))
+	}
+}
+
+func (l *ZapLogger) Error(ctx context.Context, msg string, data ...interface{}) {
+	if l.level >= logger.Error {
+		l.log(ctx).Error(fmt.Sprintf(msg, data...))
+	}
+}
+
+// Trace 记录SQL执行结果：错误、慢查询，info级别时记录全部SQL，忽略记录不存在的错误
+func (l *ZapLogger) Trace(ctx context.Context, begin time.Time, fc func() (sql string, rowsAffected int64), err error) {
+	if l.level <= logger.Silent {
+		return
+	}
+	elapsed := time.Since(begin)
+	fields := func() []zap.Field {
+		sql, rows := fc()
+		return []zap.Field{zap.String("sql", sql), zap.Int64("rows", rows), zap.Duration("elapsed", elapsed)}
+	}
+	switch {
+	case err != nil && l.level >= logger.Error && !errors.Is(err, gorm.ErrRecordNotFound):
+		l.log(ctx).Error("sql error", append(fields(), zap.Error(err))...)
+	case l.slowThreshold > 0 && elapsed > l.slowThreshold && l.level >= logger.Warn:
+		l.log(ctx).Warn("slow sql", append(fields(), zap.Duration("threshold", l.slowThreshold))...)
+	case l.level >= logger.Info:
+		l.log(ctx).Info("sql", fields()...)
+	}
+}
+
+// ParamsFilter 参数脱敏时不输出参数值
+func (l *ZapLogger) ParamsFilter(_ context.Context, sql string, params ...interface{}) (string, []interface{}) {
+	if l.redact {
+		return sql, nil
+	}
+	return sql, params
+}
+
+// log 带调用位置和请求ID的日志对象
+func (l *ZapLogger) log(ctx context.Context) *zap.Logger {
+	log := zaplog.Logger.With(zap.String("caller", caller()))
+	if id := zaplog.TraceID(ctx); id != "" {
+		log = log.With(zap.String("traceId", id))
+	}
+	return log
+}
+
+// caller 业务代码中执行SQL的位置，跳过gorm及本包
+func caller() string {
+	for i := 2; i < 20; i++ {
+		_, file, line, ok := runtime.Caller(i)
+		if !ok {
+			break
+		}
+		if strings.Contains(file, "gorm.io/") || filepath.Dir(file) == packageDir && !strings.HasSuffix(file, "_test.go") {
+			continue
+		}
+		return file + ":" + strconv.Itoa(line)
+	}
+	return ""
+}
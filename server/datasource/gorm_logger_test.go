@@ -0,0 +1,82 @@
+package datasource
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Domingor/go-blackbox/server/zaplog"
+	"github.com/glebarez/sqlite"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"gorm.io/gorm"
+)
+
+// observeLogs 替换zaplog记录日志
+func observeLogs(t *testing.T) *observer.ObservedLogs {
+	core, logs := observer.New(zapcore.DebugLevel)
+	old := zaplog.Logger
+	zaplog.Logger = zap.New(core)
+	t.Cleanup(func() { zaplog.Logger = old })
+	return logs
+}
+
+func newLoggedDb(t *testing.T, config LogConfig) *gorm.DB {
+	l, err := NewZapLogger(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "app.db")), &gorm.Config{Logger: l})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.AutoMigrate(&hero{}); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestZapLogger(t *testing.T) {
+	if _, err := NewZapLogger(LogConfig{Level: "verbose"}); err == nil {
+		t.Error("unknown level should fail")
+	}
+
+	db := newLoggedDb(t, LogConfig{Level: "info", Redact: true})
+	logs := observeLogs(t)
+	ctx := zaplog.WithTraceID(context.Background(), "req-1")
+	db.WithContext(ctx).Create(&hero{Name: "Starlight"})
+	entries := logs.FilterMessage("sql").All()
+	if len(entries) != 1 {
+		t.Fatalf("info level should log every sql but get %d", len(entries))
+	}
+	fields := entries[0].ContextMap()
+	if sql := fields["sql"].(string); strings.Contains(sql, "Starlight") || !strings.Contains(sql, "?") {
+		t.Errorf("params should be redacted but get %s", sql)
+	}
+	if fields["rows"] != int64(1) || fields["traceId"] != "req-1" || !strings.Contains(fields["caller"].(string), "gorm_logger_test.go:") {
+		t.Errorf("unexpected fields %v", fields)
+	}
+
+	// warn 级别只记录错误和慢查询，忽略记录不存在
+	db = newLoggedDb(t, LogConfig{SlowThreshold: time.Nanosecond})
+	logs = observeLogs(t)
+	var h hero
+	db.First(&h, 404)
+	db.Exec("SELECT * FROM villain")
+	if logs.FilterMessage("sql").Len() != 0 || logs.FilterMessage("sql error").Len() != 1 {
+		t.Errorf("warn level should only log errors but get %v", logs.All())
+	}
+	if slow := logs.FilterMessage("slow sql").All(); len(slow) != 1 || !strings.Contains(slow[0].ContextMap()["sql"].(string), "404") {
+		t.Errorf("slow sql should be logged with params but get %v", slow)
+	}
+
+	db = newLoggedDb(t, LogConfig{Level: "silent"})
+	logs = observeLogs(t)
+	db.Exec("SELECT * FROM villain")
+	if logs.Len() != 0 {
+		t.Error("silent level should not log")
+	}
+}
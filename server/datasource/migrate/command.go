@@ -0,0 +1,76 @@
+package migrate
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+)
+
+// Usage 迁移命令说明
+const Usage = `usage: migrate <command>
+  up           执行全部未执行的迁移
+  down [n]     回滚最近n个迁移，默认1
+  status       查看迁移状态
+  redo         回滚并重新执行最近一次迁移`
+
+// Command 执行迁移命令 up、down [n]、status、redo，结果输出到w
+func Command(ctx context.Context, m *Migrator, args []string, w io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("migrate: missing command\n%s", Usage)
+	}
+	switch args[0] {
+	case "up":
+		applied, err := m.Up(ctx)
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(w, "applied %d migration(s) %v\n", len(applied), applied)
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			n, err := strconv.Atoi(args[1])
+			if err != nil || n <= 0 {
+				return fmt.Errorf("migrate: invalid steps %s", args[1])
+			}
+			steps = n
+		}
+		reverted, err := m.Down(ctx, steps)
+		if err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(w, "reverted %d migration(s) %v\n", len(reverted), reverted)
+	case "redo":
+		version, err := m.Redo(ctx)
+		if err != nil {
+			return err
+		}
+		if version == 0 {
+			_, _ = fmt.Fprintln(w, "no migration applied")
+			return nil
+		}
+		_, _ = fmt.Fprintf(w, "redo migration %d\n", version)
+	case "status":
+		status, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+		_, _ = fmt.Fprintln(tw, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+		for _, s := range status {
+			state, at := "pending", ""
+			if s.Applied {
+				state, at = "applied", s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			if s.Missing {
+				state = "missing"
+			}
+			_, _ = fmt.Fprintf(tw, "%d\t%s\t%s\t%s\n", s.Version, s.Name, state, at)
+		}
+		return tw.Flush()
+	default:
+		return fmt.Errorf("migrate: unknown command %s\n%s", args[0], Usage)
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"hash/fnv"
+
+	"gorm.io/gorm"
+)
+
+// LockTimeout mysql等待迁移锁的最长时间，单位秒
+const LockTimeout = 600
+
+// lock 获取迁移锁，其他实例阻塞等待，执行结束后释放。
+// postgres 使用会话级 advisory lock，mysql 使用 GET_LOCK，锁与连接绑定，返回绑定到持锁连接的会话，
+// 迁移在该连接上执行，连接池 maxOpenConns 为1时也不会等待被锁占用的连接；sqlite 为单机文件数据库不加锁，返回原会话
+func lock(ctx context.Context, db *gorm.DB, name string) (locked *gorm.DB, unlock func(), err error) {
+	dialect := db.Dialector.Name()
+	if dialect != "postgres" && dialect != "mysql" {
+		return db, func() {}, nil
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, nil, err
+	}
+	// 锁与连接绑定，持有期间占用一个连接
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	name = "migrate:" + name
+	var release func() error
+	if dialect == "postgres" {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(name))
+		key := int64(h.Sum64())
+		_, err = conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", key)
+		release = func() error {
+			_, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", key)
+			return err
+		}
+	} else {
+		var ok sql.NullInt64
+		if err = conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", name, LockTimeout).Scan(&ok); err == nil && ok.Int64 != 1 {
+			err = errors.New("migrate: wait for migration lock timeout")
+		}
+		release = func() error {
+			_, err := conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", name)
+			return err
+		}
+	}
+	if err != nil {
+		_ = conn.Close()
+		return nil, nil, err
+	}
+	locked = db.Session(&gorm.Session{})
+	locked.Statement.ConnPool = conn
+	return locked, func() {
+		_ = release()
+		_ = conn.Close()
+	}, nil
+}
@@ -0,0 +1,85 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// pgServer 记录执行语句的postgres连接，查询均返回空结果
+type pgServer struct {
+	mu    sync.Mutex
+	execs []string
+}
+
+func (s *pgServer) Connect(context.Context) (driver.Conn, error) { return pgConn{s}, nil }
+func (s *pgServer) Driver() driver.Driver                        { return nil }
+
+type pgConn struct{ s *pgServer }
+
+func (pgConn) Prepare(string) (driver.Stmt, error) { return nil, driver.ErrSkip }
+func (pgConn) Close() error                        { return nil }
+func (pgConn) Begin() (driver.Tx, error)           { return pgTx{}, nil }
+
+func (c pgConn) ExecContext(_ context.Context, query string, _ []driver.NamedValue) (driver.Result, error) {
+	c.s.mu.Lock()
+	c.s.execs = append(c.s.execs, query)
+	c.s.mu.Unlock()
+	return driver.RowsAffected(1), nil
+}
+
+func (c pgConn) QueryContext(_ context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	_, _ = c.ExecContext(context.Background(), query, args)
+	return emptyRows{}, nil
+}
+
+type pgTx struct{}
+
+func (pgTx) Commit() error   { return nil }
+func (pgTx) Rollback() error { return nil }
+
+type emptyRows struct{}
+
+func (emptyRows) Columns() []string         { return nil }
+func (emptyRows) Close() error              { return nil }
+func (emptyRows) Next([]driver.Value) error { return io.EOF }
+
+func TestLockSingleConnection(t *testing.T) {
+	server := &pgServer{}
+	sqlDB := sql.OpenDB(server)
+	sqlDB.SetMaxOpenConns(1)
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sqlDB}), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := New(db, []Migration{backfill}).Up(context.Background())
+		done <- err
+	}()
+	select {
+	case err = <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("migration should run on the locked connection when pool size is 1")
+	}
+
+	server.mu.Lock()
+	defer server.mu.Unlock()
+	executed := strings.Join(server.execs, "\n")
+	if !strings.Contains(executed, "pg_advisory_lock") || !strings.Contains(executed, "UPDATE hero") || !strings.Contains(executed, "pg_advisory_unlock") {
+		t.Errorf("migration should run between lock and unlock but get %s", executed)
+	}
+}
@@ -0,0 +1,384 @@
+package migrate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Domingor/go-blackbox/server/datasource"
+	"github.com/Domingor/go-blackbox/server/zaplog"
+	"gorm.io/gorm"
+)
+
+/**
+* @Description: 版本化数据库迁移，弥补 AutoMigrate 无法重命名、回填、删除字段的不足。
+* 迁移来源为 embed.FS 中编号的 up/down SQL 文件或Go函数，已执行的版本记录在 schema_migration 表，
+* 执行期间持有数据库锁，多实例同时启动时只有一个实例执行迁移
+ */
+
+// DefaultTable 记录已执行版本的表名
+const DefaultTable = "schema_migration"
+
+var (
+	// ErrIrreversible 迁移没有定义回滚
+	ErrIrreversible = errors.New("migrate: migration has no down")
+	// ErrMissing 已执行的版本在迁移来源中不存在
+	ErrMissing = errors.New("migrate: applied migration not found in source")
+)
+
+// fileName 迁移文件名，如 0001_create_user.up.sql
+var fileName = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+
+// Migration 单个迁移，Up、Down 在事务中执行
+type Migration struct {
+	Version int64
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error // 为nil时不可回滚
+}
+
+// Version 已执行的迁移版本
+type Version struct {
+	Version   int64     `gorm:"primaryKey;autoIncrement:false" json:"version"`
+	Name      string    `gorm:"size:255" json:"name"`
+	AppliedAt time.Time `json:"appliedAt"`
+}
+
+// Status 迁移状态
+type Status struct {
+	Version   int64      `json:"version"`
+	Name      string     `json:"name"`
+	Applied   bool       `json:"applied"`
+	AppliedAt *time.Time `json:"appliedAt,omitempty"`
+	Missing   bool       `json:"missing"` // 已执行但迁移来源中不存在
+}
+
+// Load 读取目录下编号的 up/down SQL 文件，与Go迁移合并后按版本排序，版本重复时返回错误
+func Load(fsys fs.FS, dir string, migrations ...Migration) ([]Migration, error) {
+	byVersion := make(map[int64]*Migration)
+	for i := range migrations {
+		m := migrations[i]
+		if _, ok := byVersion[m.Version]; ok {
+			return nil, fmt.Errorf("migrate: duplicate version %d", m.Version)
+		}
+		byVersion[m.Version] = &m
+	}
+
+	if fsys != nil {
+		entries, err := fs.ReadDir(fsys, dir)
+		if err != nil {
+			return nil, err
+		}
+		sqlVersions := make(map[int64]bool)
+		for _, entry := range entries {
+			match := fileName.FindStringSubmatch(entry.Name())
+			if entry.IsDir() || match == nil {
+				continue
+			}
+			version, err := strconv.ParseInt(match[1], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("migrate: invalid version of %s: %w", entry.Name(), err)
+			}
+			m, ok := byVersion[version]
+			if !ok {
+				m = &Migration{Version: version, Name: match[2]}
+				byVersion[version] = m
+				sqlVersions[version] = true
+			} else if !sqlVersions[version] || m.Name != match[2] {
+				return nil, fmt.Errorf("migrate: duplicate version %d", version)
+			}
+			content, err := fs.ReadFile(fsys, path.Join(dir, entry.Name()))
+			if err != nil {
+				return nil, err
+			}
+			if match[3] == "up" {
+				m.Up = execSQL(string(content))
+			} else {
+				m.Down = execSQL(string(content))
+			}
+		}
+	}
+
+	result := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == nil {
+			return nil, fmt.Errorf("migrate: version %d has no up", m.Version)
+		}
+		result = append(result, *m)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Version < result[j].Version })
+	return result, nil
+}
+
+// execSQL 执行SQL文件内容，文件可包含多条语句，拆分后逐条执行，连接无需开启 multiStatements
+func execSQL(sql string) func(tx *gorm.DB) error {
+	statements := splitStatements(sql)
+	return func(tx *gorm.DB) error {
+		for _, statement := range statements {
+			if err := tx.Exec(statement).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+// splitStatements 按分号拆分SQL语句，忽略引号、注释及 postgres $$ 函数体中的分号，只有注释的片段不执行；
+// 不支持 mysql DELIMITER 语法，存储过程等需使用Go迁移
+func splitStatements(sql string) []string {
+	var statements []string
+	start, hasCode := 0, false
+	for i := 0; i < len(sql); i++ {
+		c := sql[i]
+		switch {
+		case c == '-' && strings.HasPrefix(sql[i:], "--"):
+			if end := strings.IndexByte(sql[i:], '\n'); end >= 0 {
+				i += end
+			} else {
+				i = len(sql)
+			}
+			continue
+		case c == '/' && strings.HasPrefix(sql[i:], "/*"):
+			if end := strings.Index(sql[i+2:], "*/"); end >= 0 {
+				i += end + 3
+			} else {
+				i = len(sql)
+			}
+			continue
+		case c == ';':
+			if hasCode {
+				statements = append(statements, strings.TrimSpace(sql[start:i]))
+			}
+			start, hasCode = i+1, false
+			continue
+		case c == '\'' || c == '"' || c == '`':
+			// 引号内的内容，两个连续引号为转义
+			for i++; i < len(sql); i++ {
+				if sql[i] == c {
+					if i+1 < len(sql) && sql[i+1] == c {
+						i++
+						continue
+					}
+					break
+				}
+			}
+		case c == '$':
+			if tag := dollarTag.FindString(sql[i:]); tag != "" {
+				if end := strings.Index(sql[i+len(tag):], tag); end >= 0 {
+					i += len(tag) + end + len(tag) - 1
+				} else {
+					i = len(sql)
+				}
+			}
+		}
+		if c != ' ' && c != '\t' && c != '\n' && c != '\r' {
+			hasCode = true
+		}
+	}
+	if hasCode {
+		statements = append(statements, strings.TrimSpace(sql[start:]))
+	}
+	return statements
+}
+
+// dollarTag postgres 美元符号引用的开始标记，如 $$、$body$
+var dollarTag = regexp.MustCompile(`^\$[A-Za-z_]*\$`)
+
+// Migrator 迁移执行器
+type Migrator struct {
+	db         *gorm.DB
+	table      string
+	migrations []Migration
+}
+
+// New 创建迁移执行器，migrations 通常由 Load 生成
+func New(db *gorm.DB, migrations []Migration) *Migrator {
+	return &Migrator{db: db, table: DefaultTable, migrations: migrations}
+}
+
+// WithTable 修改记录版本的表名
+func (m *Migrator) WithTable(table string) *Migrator {
+	m.table = table
+	return m
+}
+
+// Up 执行全部未执行的迁移，返回本次执行的版本
+func (m *Migrator) Up(ctx context.Context) (applied []int64, err error) {
+	err = m.locked(ctx, func(db *gorm.DB, versions map[int64]Version) error {
+		for _, migration := range m.migrations {
+			if _, ok := versions[migration.Version]; ok {
+				continue
+			}
+			if err := m.apply(db, migration); err != nil {
+				return err
+			}
+			applied = append(applied, migration.Version)
+		}
+		return nil
+	})
+	return
+}
+
+// Down 按版本倒序回滚最近执行的 steps 个迁移，返回回滚的版本
+func (m *Migrator) Down(ctx context.Context, steps int) (reverted []int64, err error) {
+	err = m.locked(ctx, func(db *gorm.DB, versions map[int64]Version) error {
+		for _, version := range latest(versions, steps) {
+			if err := m.revert(db, version); err != nil {
+				return err
+			}
+			reverted = append(reverted, version)
+		}
+		return nil
+	})
+	return
+}
+
+// Redo 回滚并重新执行最近一次迁移
+func (m *Migrator) Redo(ctx context.Context) (version int64, err error) {
+	err = m.locked(ctx, func(db *gorm.DB, versions map[int64]Version) error {
+		last := latest(versions, 1)
+		if len(last) == 0 {
+			return nil
+		}
+		version = last[0]
+		if err := m.revert(db, version); err != nil {
+			return err
+		}
+		migration, _ := m.find(version)
+		return m.apply(db, migration)
+	})
+	return
+}
+
+// Status 按版本返回全部迁移的执行状态，包括已执行但来源中不存在的版本
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	db := m.session(ctx)
+	if err := m.ensureTable(db); err != nil {
+		return nil, err
+	}
+	versions, err := m.versions(db)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		s := Status{Version: migration.Version, Name: migration.Name}
+		if v, ok := versions[migration.Version]; ok {
+			s.Applied, s.AppliedAt = true, &v.AppliedAt
+			delete(versions, migration.Version)
+		}
+		result = append(result, s)
+	}
+	for _, v := range versions {
+		v := v
+		result = append(result, Status{Version: v.Version, Name: v.Name, Applied: true, AppliedAt: &v.AppliedAt, Missing: true})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Version < result[j].Version })
+	return result, nil
+}
+
+// locked 持有迁移锁后读取已执行版本并执行fn
+func (m *Migrator) locked(ctx context.Context, fn func(db *gorm.DB, versions map[int64]Version) error) error {
+	db, unlock, err := lock(ctx, m.session(ctx), m.table)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	if err = m.ensureTable(db); err != nil {
+		return err
+	}
+	versions, err := m.versions(db)
+	if err != nil {
+		return err
+	}
+	return fn(db, versions)
+}
+
+// session 迁移始终使用主库
+func (m *Migrator) session(ctx context.Context) *gorm.DB {
+	return m.db.WithContext(datasource.UsePrimary(ctx))
+}
+
+func (m *Migrator) ensureTable(db *gorm.DB) error {
+	return db.Table(m.table).AutoMigrate(&Version{})
+}
+
+func (m *Migrator) versions(db *gorm.DB) (map[int64]Version, error) {
+	var rows []Version
+	if err := db.Table(m.table).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	versions := make(map[int64]Version, len(rows))
+	for _, v := range rows {
+		versions[v.Version] = v
+	}
+	return versions, nil
+}
+
+func (m *Migrator) find(version int64) (Migration, bool) {
+	for _, migration := range m.migrations {
+		if migration.Version == version {
+			return migration, true
+		}
+	}
+	return Migration{}, false
+}
+
+// apply 在事务中执行迁移并记录版本
+func (m *Migrator) apply(db *gorm.DB, migration Migration) error {
+	start := time.Now()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := migration.Up(tx); err != nil {
+			return err
+		}
+		return tx.Table(m.table).Create(&Version{Version: migration.Version, Name: migration.Name, AppliedAt: time.Now()}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("migrate: up %d_%s failed: %w", migration.Version, migration.Name, err)
+	}
+	zaplog.SugaredLogger.Infof("migration %d_%s applied in %s", migration.Version, migration.Name, time.Since(start))
+	return nil
+}
+
+// revert 在事务中回滚迁移并删除版本记录
+func (m *Migrator) revert(db *gorm.DB, version int64) error {
+	migration, ok := m.find(version)
+	if !ok {
+		return fmt.Errorf("%w: %d", ErrMissing, version)
+	}
+	if migration.Down == nil {
+		return fmt.Errorf("%w: %d_%s", ErrIrreversible, migration.Version, migration.Name)
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := migration.Down(tx); err != nil {
+			return err
+		}
+		return tx.Table(m.table).Where("version = ?", version).Delete(&Version{}).Error
+	})
+	if err != nil {
+		return fmt.Errorf("migrate: down %d_%s failed: %w", migration.Version, migration.Name, err)
+	}
+	zaplog.SugaredLogger.Infof("migration %d_%s reverted", migration.Version, migration.Name)
+	return nil
+}
+
+// latest 版本倒序的前n个已执行版本
+func latest(versions map[int64]Version, n int) []int64 {
+	result := make([]int64, 0, len(versions))
+	for version := range versions {
+		result = append(result, version)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i] > result[j] })
+	if n < len(result) {
+		result = result[:n]
+	}
+	return result
+}
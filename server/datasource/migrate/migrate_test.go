@@ -0,0 +1,147 @@
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/Domingor/go-blackbox/server/zaplog"
+	"github.com/glebarez/sqlite"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestMain(m *testing.M) {
+	zaplog.Logger = zap.NewNop()
+	zaplog.SugaredLogger = zaplog.Logger.Sugar()
+	os.Exit(m.Run())
+}
+
+var files = fstest.MapFS{
+	"sql/0001_create_hero.up.sql":   {Data: []byte("CREATE TABLE hero (id INTEGER PRIMARY KEY, name TEXT);\nINSERT INTO hero (name) VALUES ('Starlight');")},
+	"sql/0001_create_hero.down.sql": {Data: []byte("DROP TABLE hero;")},
+	"sql/0003_rename_name.up.sql":   {Data: []byte("ALTER TABLE hero RENAME COLUMN name TO alias;")},
+	"sql/0003_rename_name.down.sql": {Data: []byte("ALTER TABLE hero RENAME COLUMN alias TO name;")},
+	"sql/README.md":                 {Data: []byte("ignored")},
+}
+
+// backfill Go迁移，不可回滚
+var backfill = Migration{
+	Version: 2,
+	Name:    "backfill",
+	Up: func(tx *gorm.DB) error {
+		return tx.Exec("UPDATE hero SET name = upper(name)").Error
+	},
+}
+
+func newTestDb(t *testing.T) *gorm.DB {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "app.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestLoad(t *testing.T) {
+	migrations, err := Load(files, "sql", backfill)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) != 3 || migrations[0].Name != "create_hero" || migrations[1].Name != "backfill" || migrations[2].Version != 3 {
+		t.Errorf("migrations should be sorted by version but get %+v", migrations)
+	}
+	if _, err = Load(files, "sql", Migration{Version: 1, Name: "dup", Up: backfill.Up}); err == nil {
+		t.Error("duplicate version should fail")
+	}
+	if _, err = Load(fstest.MapFS{"sql/0001_x.down.sql": {}}, "sql"); err == nil {
+		t.Error("migration without up should fail")
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	sql := `-- 建表
+CREATE TABLE hero (name TEXT DEFAULT 'a;b', note TEXT DEFAULT 'it''s;');
+/* 注释; */
+INSERT INTO hero (name) VALUES ("x;y");
+CREATE FUNCTION touch() RETURNS trigger AS $$ BEGIN NEW.name := 'x'; RETURN NEW; END; $$ LANGUAGE plpgsql;
+-- 结束;
+`
+	statements := splitStatements(sql)
+	if len(statements) != 3 || !strings.HasPrefix(statements[0], "-- 建表\nCREATE TABLE") ||
+		!strings.HasSuffix(statements[0], "'it''s;')") || !strings.HasSuffix(statements[2], "LANGUAGE plpgsql") {
+		t.Errorf("unexpected statements %q", statements)
+	}
+}
+
+func TestMigrator(t *testing.T) {
+	ctx := context.Background()
+	db := newTestDb(t)
+	migrations, _ := Load(files, "sql", backfill)
+	m := New(db, migrations)
+
+	applied, err := m.Up(ctx)
+	if err != nil || len(applied) != 3 {
+		t.Fatalf("want 3 applied but get %v %v", applied, err)
+	}
+	var alias string
+	db.Raw("SELECT alias FROM hero").Scan(&alias)
+	if alias != "STARLIGHT" {
+		t.Errorf("migrations should run in order but get %q", alias)
+	}
+	if applied, _ = m.Up(ctx); len(applied) != 0 {
+		t.Errorf("applied migrations should not run again but get %v", applied)
+	}
+
+	if _, err = m.Redo(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if reverted, err := m.Down(ctx, 1); err != nil || len(reverted) != 1 || reverted[0] != 3 {
+		t.Fatalf("want version 3 reverted but get %v %v", reverted, err)
+	}
+	if _, err = m.Down(ctx, 1); !errors.Is(err, ErrIrreversible) {
+		t.Errorf("want ErrIrreversible but get %v", err)
+	}
+
+	status, err := m.Status(ctx)
+	if err != nil || len(status) != 3 || !status[0].Applied || !status[1].Applied || status[2].Applied {
+		t.Fatalf("unexpected status %+v %v", status, err)
+	}
+
+	// 迁移来源中已删除的版本
+	if _, err = New(db, migrations[:1]).Down(ctx, 1); !errors.Is(err, ErrMissing) {
+		t.Errorf("want ErrMissing but get %v", err)
+	}
+	if status, _ = New(db, migrations[:1]).Status(ctx); len(status) != 2 || !status[1].Missing {
+		t.Errorf("unknown applied version should be missing but get %+v", status)
+	}
+}
+
+func TestCommand(t *testing.T) {
+	ctx := context.Background()
+	migrations, _ := Load(files, "sql")
+	m := New(newTestDb(t), migrations)
+
+	var out bytes.Buffer
+	for _, args := range [][]string{{"up"}, {"down", "2"}, {"status"}} {
+		if err := Command(ctx, m, args, &out); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if !strings.Contains(out.String(), "applied 2 migration(s) [1 3]") ||
+		!strings.Contains(out.String(), "reverted 2 migration(s) [3 1]") ||
+		!strings.Contains(out.String(), "create_hero  pending") {
+		t.Errorf("unexpected output\n%s", out.String())
+	}
+	if err := Command(ctx, m, []string{"down", "x"}, &out); err == nil {
+		t.Error("invalid steps should fail")
+	}
+	if err := Command(ctx, m, []string{"drop"}, &out); err == nil {
+		t.Error("unknown command should fail")
+	}
+}
@@ -0,0 +1,278 @@
+package datasource
+
+import (
+	"context"
+	"crypto/sha1"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Domingor/go-blackbox/server/cache"
+	"github.com/Domingor/go-blackbox/server/zaplog"
+	"gorm.io/gorm"
+	"gorm.io/gorm/callbacks"
+)
+
+/**
+* @Description: gorm查询结果缓存插件，查询通过 Cached 显式开启缓存，增删改后按表清除缓存。
+* 事务中的查询不读写缓存，事务中的写操作在执行后及提交后各清除一次，避免提交前其他请求重新加载旧数据；
+* 原生SQL写入只有通过 db.Table(name).Exec 执行时自动清除，其他情况需调用 InvalidateCache
+ */
+
+const (
+	// queryCacheSetting 查询缓存参数在 Statement.Settings 中的key
+	queryCacheSetting = "query_cache:setting"
+	// QueryCachePrefix 查询结果缓存key前缀
+	QueryCachePrefix = "gorm:query:"
+	// QueryCacheTagPrefix 表标签前缀，表数据变更时清除该标签下的全部缓存
+	QueryCacheTagPrefix = "gorm:table:"
+)
+
+var queryCacher cache.Rediser // GormInit 时注册查询缓存插件使用的缓存
+
+// UseCache 设置查询缓存使用的缓存实例，需要在 GormInit 之前调用
+func UseCache(r cache.Rediser) {
+	queryCacher = r
+}
+
+// cacheSetting 查询缓存参数
+type cacheSetting struct {
+	ttl    time.Duration
+	tables []string
+}
+
+// Cached 查询结果缓存ttl时长，用于 db.Scopes(datasource.Cached(time.Minute)).Find(&list)
+// 默认在查询主表数据变更时失效，联表查询可通过tables追加关联表
+func Cached(ttl time.Duration, tables ...string) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Set(queryCacheSetting, cacheSetting{ttl: ttl, tables: tables})
+	}
+}
+
+// queryResult 缓存的查询结果
+type queryResult struct {
+	RowsAffected int64  `msgpack:"r" json:"r"`
+	Dest         []byte `msgpack:"d" json:"d"` // 编码后的查询结果
+}
+
+// QueryCache gorm查询缓存插件
+type QueryCache struct {
+	cache cache.Rediser
+	alias string // 数据源别名，多个数据源存在同名表时隔离缓存
+}
+
+// NewQueryCache 创建查询缓存插件，通过 db.Use 注册
+func NewQueryCache(r cache.Rediser) *QueryCache {
+	return &QueryCache{cache: r}
+}
+
+// WithAlias 按数据源别名隔离缓存key和表标签
+func (qc *QueryCache) WithAlias(alias string) *QueryCache {
+	qc.alias = alias
+	return qc
+}
+
+// table 带数据源别名的表名
+func (qc *QueryCache) table(table string) string {
+	if qc.alias == "" {
+		return table
+	}
+	return qc.alias + ":" + table
+}
+
+// Name 插件名称
+func (qc *QueryCache) Name() string {
+	return "gorm:query_cache"
+}
+
+// Initialize 替换查询回调，在增删改之后清除对应表的缓存，并接管事务的提交
+func (qc *QueryCache) Initialize(db *gorm.DB) (err error) {
+	if err = db.Callback().Query().Replace("gorm:query", qc.query); err != nil {
+		return
+	}
+	if err = db.Callback().Create().After("gorm:create").Register("query_cache:invalidate", qc.invalidate); err != nil {
+		return
+	}
+	if err = db.Callback().Update().After("gorm:update").Register("query_cache:invalidate", qc.invalidate); err != nil {
+		return
+	}
+	if err = db.Callback().Delete().After("gorm:delete").Register("query_cache:invalidate", qc.invalidate); err != nil {
+		return
+	}
+	if err = db.Callback().Raw().After("gorm:raw").Register("query_cache:invalidate", qc.invalidate); err != nil {
+		return
+	}
+	db.ConnPool = &cachePool{ConnPool: db.ConnPool, qc: qc}
+	db.Statement.ConnPool = db.ConnPool
+	return
+}
+
+// InvalidateCache 清除数据源中指定表的查询缓存，用于 Exec 等无法识别表名的写操作
+func InvalidateCache(db *gorm.DB, tables ...string) error {
+	qc, ok := db.Config.Plugins[(&QueryCache{}).Name()].(*QueryCache)
+	if !ok {
+		return nil
+	}
+	if _, err := qc.cache.InvalidateTags(db.Statement.Context, qc.tags("", tables)...); err != nil {
+		return err
+	}
+	return nil
+}
+
+// query 未开启缓存时按原流程查询；开启时命中缓存直接填充结果，未命中时查询数据库并写入缓存
+func (qc *QueryCache) query(db *gorm.DB) {
+	value, ok := db.Get(queryCacheSetting)
+	if !ok || db.Error != nil || db.DryRun {
+		callbacks.Query(db)
+		return
+	}
+	// 事务中可能读到未提交的数据，不读写缓存
+	if _, inTx := db.Statement.ConnPool.(gorm.TxCommitter); inTx {
+		callbacks.Query(db)
+		return
+	}
+	setting := value.(cacheSetting)
+
+	callbacks.BuildQuerySQL(db)
+	if db.Error != nil {
+		return
+	}
+	ctx, key := db.Statement.Context, qc.key(db)
+
+	var result queryResult
+	err := qc.cache.Get(ctx, key, &result)
+	if err == nil {
+		if err = qc.cache.Unmarshal(result.Dest, db.Statement.Dest); err == nil {
+			db.RowsAffected = result.RowsAffected
+			if db.RowsAffected == 0 && db.Statement.RaiseErrorOnNotFound {
+				db.AddError(gorm.ErrRecordNotFound)
+			}
+			return
+		}
+	}
+	if !errors.Is(err, cache.ErrCacheMiss) {
+		zaplog.SugaredLogger.Debugf("query cache get %s failed, querying database: %s", key, err)
+	}
+
+	callbacks.Query(db)
+	if db.Error != nil && !errors.Is(db.Error, gorm.ErrRecordNotFound) {
+		return
+	}
+	if result.Dest, err = qc.cache.Marshal(db.Statement.Dest); err != nil {
+		zaplog.SugaredLogger.Debugf("query cache marshal %s failed: %s", key, err)
+		return
+	}
+	result.RowsAffected = db.RowsAffected
+	if err = qc.cache.SetWithTags(ctx, key, result, setting.ttl, qc.tags(db.Statement.Table, setting.tables)...); err != nil {
+		zaplog.SugaredLogger.Debugf("query cache set %s failed: %s", key, err)
+	}
+}
+
+// invalidate 写操作成功后清除该表的查询缓存，事务中的写操作在提交后再清除一次
+func (qc *QueryCache) invalidate(db *gorm.DB) {
+	if db.Error != nil || db.DryRun || db.Statement.Table == "" {
+		return
+	}
+	if tx, ok := db.Statement.ConnPool.(*cacheTx); ok {
+		tx.written(db.Statement.Table)
+	}
+	if _, err := qc.cache.InvalidateTags(db.Statement.Context, qc.tags(db.Statement.Table, nil)...); err != nil {
+		zaplog.SugaredLogger.Warnf("query cache invalidate table %s failed: %s", db.Statement.Table, err)
+	}
+}
+
+// key 按表名和完整SQL生成缓存key
+func (qc *QueryCache) key(db *gorm.DB) string {
+	sql := db.Dialector.Explain(db.Statement.SQL.String(), db.Statement.Vars...)
+	sum := sha1.Sum([]byte(sql))
+	return QueryCachePrefix + qc.table(db.Statement.Table) + ":" + hex.EncodeToString(sum[:])
+}
+
+// tags 查询依赖的表标签
+func (qc *QueryCache) tags(table string, tables []string) []string {
+	tags := make([]string, 0, len(tables)+1)
+	for _, t := range append([]string{table}, tables...) {
+		if t != "" {
+			tags = append(tags, QueryCacheTagPrefix+qc.table(t))
+		}
+	}
+	return tags
+}
+
+// cachePool 开启事务时返回 cacheTx，记录事务中写入的表
+type cachePool struct {
+	gorm.ConnPool
+	qc *QueryCache
+}
+
+func (p *cachePool) BeginTx(ctx context.Context, opts *sql.TxOptions) (gorm.ConnPool, error) {
+	var (
+		tx  gorm.ConnPool
+		err error
+	)
+	switch beginner := p.ConnPool.(type) {
+	case gorm.TxBeginner:
+		tx, err = beginner.BeginTx(ctx, opts)
+	case gorm.ConnPoolBeginner:
+		tx, err = beginner.BeginTx(ctx, opts)
+	default:
+		err = gorm.ErrInvalidTransaction
+	}
+	if err != nil {
+		return nil, err
+	}
+	committer, ok := tx.(gorm.TxCommitter)
+	if !ok {
+		return nil, gorm.ErrInvalidTransaction
+	}
+	return &cacheTx{ConnPool: tx, committer: committer, qc: p.qc}, nil
+}
+
+// GetDBConn 供 db.DB() 获取原连接池
+func (p *cachePool) GetDBConn() (*sql.DB, error) {
+	switch pool := p.ConnPool.(type) {
+	case *sql.DB:
+		return pool, nil
+	case gorm.GetDBConnector:
+		return pool.GetDBConn()
+	}
+	return nil, fmt.Errorf("datasource: unsupported conn pool %T", p.ConnPool)
+}
+
+// cacheTx 提交成功后清除事务中写入表的查询缓存
+type cacheTx struct {
+	gorm.ConnPool
+	committer gorm.TxCommitter
+	qc        *QueryCache
+	mu        sync.Mutex
+	tables    []string
+}
+
+func (tx *cacheTx) written(table string) {
+	tx.mu.Lock()
+	defer tx.mu.Unlock()
+	tx.tables = append(tx.tables, table)
+}
+
+func (tx *cacheTx) Commit() error {
+	if err := tx.committer.Commit(); err != nil {
+		return err
+	}
+	tx.mu.Lock()
+	tables := tx.tables
+	tx.mu.Unlock()
+	if len(tables) == 0 {
+		return nil
+	}
+	if _, err := tx.qc.cache.InvalidateTags(context.Background(), tx.qc.tags("", tables)...); err != nil {
+		zaplog.SugaredLogger.Warnf("query cache invalidate tables %v after commit failed: %s", tables, err)
+	}
+	return nil
+}
+
+func (tx *cacheTx) Rollback() error {
+	return tx.committer.Rollback()
+}
@@ -0,0 +1,239 @@
+package datasource
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Domingor/go-blackbox/server/cache"
+	"github.com/Domingor/go-blackbox/server/zaplog"
+	"go.uber.org/zap"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestMain(m *testing.M) {
+	zaplog.Logger = zap.NewNop()
+	zaplog.SugaredLogger = zaplog.Logger.Sugar()
+	sql.Register("fakedb", fake)
+	os.Exit(m.Run())
+}
+
+// fakeDriver 记录执行次数的假数据库驱动，查询固定返回 rows，参数为404时返回空结果
+type fakeDriver struct {
+	queries int32
+	rows    [][]driver.Value
+}
+
+var fake = &fakeDriver{rows: [][]driver.Value{{int64(1), "Homelander"}, {int64(2), "A-Train"}}}
+
+func (*fakeDriver) Open(string) (driver.Conn, error) { return fakeConn{}, nil }
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (fakeConn) Close() error                        { return nil }
+func (fakeConn) Begin() (driver.Tx, error)           { return nodeTx{}, nil }
+
+func (fakeConn) QueryContext(_ context.Context, _ string, args []driver.NamedValue) (driver.Rows, error) {
+	atomic.AddInt32(&fake.queries, 1)
+	for _, arg := range args {
+		if arg.Value == int64(404) {
+			return &fakeRows{}, nil
+		}
+	}
+	return &fakeRows{rows: fake.rows}, nil
+}
+
+func (fakeConn) ExecContext(context.Context, string, []driver.NamedValue) (driver.Result, error) {
+	return fakeResult{}, nil
+}
+
+type fakeResult struct{}
+
+func (fakeResult) LastInsertId() (int64, error) { return 3, nil }
+func (fakeResult) RowsAffected() (int64, error) { return 1, nil }
+
+type fakeRows struct {
+	rows [][]driver.Value
+	i    int
+}
+
+func (r *fakeRows) Columns() []string { return []string{"id", "name"} }
+func (r *fakeRows) Close() error      { return nil }
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if r.i >= len(r.rows) {
+		return io.EOF
+	}
+	copy(dest, r.rows[r.i])
+	r.i++
+	return nil
+}
+
+type hero struct {
+	ID   int64
+	Name string
+}
+
+func newCachedDb(t *testing.T) *gorm.DB {
+	conn, err := sql.Open("fakedb", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: conn, WithoutReturning: true}), &gorm.Config{
+		Logger:                 logger.Discard,
+		SkipDefaultTransaction: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.Use(NewQueryCache(cache.NewMemoryCache(ctx))); err != nil {
+		t.Fatal(err)
+	}
+	atomic.StoreInt32(&fake.queries, 0)
+	return db
+}
+
+func TestQueryCache(t *testing.T) {
+	db := newCachedDb(t)
+
+	for i := 0; i < 3; i++ {
+		var heroes []hero
+		if err := db.Scopes(Cached(time.Minute)).Find(&heroes).Error; err != nil {
+			t.Fatal(err)
+		}
+		if len(heroes) != 2 || heroes[1].Name != "A-Train" {
+			t.Fatalf("unexpected result %v", heroes)
+		}
+	}
+	if n := atomic.LoadInt32(&fake.queries); n != 1 {
+		t.Errorf("cached query should hit database once but hit %d times", n)
+	}
+
+	// 未开启缓存的查询不受影响
+	var heroes []hero
+	db.Find(&heroes)
+	if n := atomic.LoadInt32(&fake.queries); n != 2 {
+		t.Errorf("uncached query should hit database but queries %d", n)
+	}
+
+	// 不同条件使用不同的缓存
+	var h hero
+	for i := 0; i < 2; i++ {
+		h = hero{}
+		db.Scopes(Cached(time.Minute)).Where("id = ?", 1).First(&h)
+	}
+	if n := atomic.LoadInt32(&fake.queries); n != 3 || h.ID != 1 {
+		t.Errorf("first should be cached separately, queries %d hero %v", n, h)
+	}
+}
+
+func TestQueryCacheNotFound(t *testing.T) {
+	db := newCachedDb(t)
+
+	for i := 0; i < 2; i++ {
+		var h hero
+		err := db.Scopes(Cached(time.Minute)).Where("id = ?", 404).First(&h).Error
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			t.Errorf("want ErrRecordNotFound but get %v", err)
+		}
+	}
+	if n := atomic.LoadInt32(&fake.queries); n != 1 {
+		t.Errorf("empty result should be cached but queries %d", n)
+	}
+}
+
+func TestQueryCacheInvalidate(t *testing.T) {
+	db := newCachedDb(t)
+	find := func() {
+		var heroes []hero
+		if err := db.Scopes(Cached(time.Minute)).Find(&heroes).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	writes := []func() error{
+		func() error { return db.Create(&hero{ID: 3, Name: "The Deep"}).Error },
+		func() error { return db.Model(&hero{ID: 3}).Update("name", "Black Noir").Error },
+		func() error { return db.Delete(&hero{ID: 3}).Error },
+	}
+	want := int32(1)
+	find()
+	for _, write := range writes {
+		if err := write(); err != nil {
+			t.Fatal(err)
+		}
+		find()
+		want++
+		if n := atomic.LoadInt32(&fake.queries); n != want {
+			t.Errorf("write should invalidate cache, want %d queries but get %d", want, n)
+		}
+	}
+
+	// 关联表变更时清除联表查询缓存
+	var heroes []hero
+	db.Scopes(Cached(time.Minute, "team")).Where("name <> ?", "").Find(&heroes)
+	db.Table("team").Where("id = ?", 1).Update("name", "The Seven")
+	db.Scopes(Cached(time.Minute, "team")).Where("name <> ?", "").Find(&heroes)
+	if n := atomic.LoadInt32(&fake.queries); n != want+2 {
+		t.Errorf("related table write should invalidate cache, want %d queries but get %d", want+2, n)
+	}
+}
+
+func TestQueryCacheTransaction(t *testing.T) {
+	db := newCachedDb(t)
+	find := func(db *gorm.DB) {
+		var heroes []hero
+		if err := db.Scopes(Cached(time.Minute)).Find(&heroes).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	queries := func() int32 { return atomic.LoadInt32(&fake.queries) }
+
+	// 事务中的查询不写入缓存，回滚后重新查询数据库
+	_ = db.Transaction(func(tx *gorm.DB) error {
+		find(tx)
+		find(tx)
+		return errors.New("rollback")
+	})
+	find(db)
+	if n := queries(); n != 3 {
+		t.Errorf("query in transaction should not use cache, want 3 queries but get %d", n)
+	}
+
+	// 写入后、提交前其他请求重新加载的缓存在提交后清除
+	tx := db.Begin()
+	tx.Model(&hero{ID: 1}).Update("name", "Soldier Boy")
+	find(db)
+	find(db)
+	if err := tx.Commit().Error; err != nil {
+		t.Fatal(err)
+	}
+	find(db)
+	if n := queries(); n != 5 {
+		t.Errorf("commit should invalidate cache again, want 5 queries but get %d", n)
+	}
+
+	// 指定表名的原生SQL写入及手动清除
+	db.Table("heros").Exec("UPDATE heros SET name = ?", "Stormfront")
+	find(db)
+	db.Exec("UPDATE heros SET name = ?", "Ezekiel")
+	if err := InvalidateCache(db, "heros"); err != nil {
+		t.Fatal(err)
+	}
+	find(db)
+	if n := queries(); n != 7 {
+		t.Errorf("raw writes should invalidate cache, want 7 queries but get %d", n)
+	}
+}
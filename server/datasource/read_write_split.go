@@ -0,0 +1,202 @@
+package datasource
+
+import (
+	"context"
+	"database/sql"
+	"math/rand"
+	"regexp"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/Domingor/go-blackbox/server/zaplog"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+/**
+* @Description: gorm读写分离插件，事务外的查询按策略路由到健康的只读副本，写操作、加锁读、事务及 UsePrimary 标记的请求使用主库；
+* 复用同一语句先查询再写入时，写入前恢复为主库连接；定期检查副本，不可用的副本暂停路由，恢复后自动重新加入
+ */
+
+// 副本选择策略
+const (
+	ReplicaRandom     = "random"     // 随机，默认
+	ReplicaRoundRobin = "roundRobin" // 轮询
+)
+
+// DefaultReplicaCheckInterval 副本健康检查默认间隔
+const DefaultReplicaCheckInterval = 10 * time.Second
+
+// selectPattern 原生SQL只有 SELECT 语句路由到副本
+var selectPattern = regexp.MustCompile(`^\s*(?i:select)\s`)
+
+// lockingPattern 加锁读，如 FOR UPDATE、FOR SHARE、LOCK IN SHARE MODE，需在主库执行
+var lockingPattern = regexp.MustCompile(`(?i)\s(for\s+(no\s+key\s+)?(update|share|key\s+share)|lock\s+in\s+share\s+mode)\b`)
+
+// primaryKey UsePrimary 在ctx中的标记
+type primaryKey struct{}
+
+// primaryPoolKey 路由到副本前的连接，保存在 Statement.Settings 中，写入前恢复
+const primaryPoolKey = "read_write_split:primary"
+
+// UsePrimary 标记请求的查询也使用主库，用于写后立即读取等不能容忍复制延迟的场景
+func UsePrimary(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// Replica 只读副本
+type Replica struct {
+	Name string // 用于日志，如副本地址
+	DB   *sql.DB
+}
+
+// replica 副本及健康状态
+type replica struct {
+	Replica
+	healthy int32
+}
+
+// ReadWriteSplit gorm读写分离插件
+type ReadWriteSplit struct {
+	policy   string
+	interval time.Duration
+	replicas []*replica
+	next     uint32 // 轮询计数
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewReadWriteSplit 创建读写分离插件，通过 db.Use 注册，interval为副本健康检查间隔
+func NewReadWriteSplit(policy string, interval time.Duration, replicas ...Replica) *ReadWriteSplit {
+	if interval <= 0 {
+		interval = DefaultReplicaCheckInterval
+	}
+	rw := &ReadWriteSplit{policy: policy, interval: interval, stop: make(chan struct{})}
+	for _, r := range replicas {
+		rw.replicas = append(rw.replicas, &replica{Replica: r, healthy: 1})
+	}
+	return rw
+}
+
+// Name 插件名称
+func (rw *ReadWriteSplit) Name() string {
+	return "gorm:read_write_split"
+}
+
+// Initialize 在查询前选择连接、写入前恢复主库连接，并开始副本健康检查
+func (rw *ReadWriteSplit) Initialize(db *gorm.DB) (err error) {
+	if err = db.Callback().Query().Before("gorm:query").Register("read_write_split:query", rw.route); err != nil {
+		return
+	}
+	if err = db.Callback().Row().Before("gorm:row").Register("read_write_split:row", rw.route); err != nil {
+		return
+	}
+	if err = db.Callback().Create().Before("gorm:create").Register("read_write_split:create", restorePrimary); err != nil {
+		return
+	}
+	if err = db.Callback().Update().Before("gorm:update").Register("read_write_split:update", restorePrimary); err != nil {
+		return
+	}
+	if err = db.Callback().Delete().Before("gorm:delete").Register("read_write_split:delete", restorePrimary); err != nil {
+		return
+	}
+	if err = db.Callback().Raw().Before("gorm:raw").Register("read_write_split:raw", restorePrimary); err != nil {
+		return
+	}
+	go rw.checkLoop()
+	return
+}
+
+// Close 停止健康检查并关闭副本连接
+func (rw *ReadWriteSplit) Close() error {
+	rw.stopOnce.Do(func() { close(rw.stop) })
+	var err error
+	for _, r := range rw.replicas {
+		if e := r.DB.Close(); e != nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// route 事务外的只读语句路由到副本
+func (rw *ReadWriteSplit) route(db *gorm.DB) {
+	if db.Error != nil {
+		return
+	}
+	// 复用的语句上次可能已路由到副本，先恢复再重新选择
+	restorePrimary(db)
+	if _, inTx := db.Statement.ConnPool.(gorm.TxCommitter); inTx {
+		return
+	}
+	if ctx := db.Statement.Context; ctx != nil && ctx.Value(primaryKey{}) != nil {
+		return
+	}
+	// 加锁读 Clauses(clause.Locking{Strength: "UPDATE"})
+	if _, locking := db.Statement.Clauses[clause.Locking{}.Name()]; locking {
+		return
+	}
+	// 原生SQL，如 Raw("UPDATE ... RETURNING").Scan()、Raw("SELECT ... FOR UPDATE").Scan()
+	if sql := db.Statement.SQL.String(); sql != "" && (!selectPattern.MatchString(sql) || lockingPattern.MatchString(sql)) {
+		return
+	}
+	if r := rw.pick(); r != nil {
+		db.Statement.Settings.Store(primaryPoolKey, db.Statement.ConnPool)
+		db.Statement.ConnPool = r.DB
+	}
+}
+
+// restorePrimary 语句已路由到副本时恢复为路由前的连接
+func restorePrimary(db *gorm.DB) {
+	if pool, ok := db.Statement.Settings.LoadAndDelete(primaryPoolKey); ok {
+		db.Statement.ConnPool = pool.(gorm.ConnPool)
+	}
+}
+
+// pick 按策略选择健康的副本，全部不可用时返回nil使用主库
+func (rw *ReadWriteSplit) pick() *replica {
+	healthy := make([]*replica, 0, len(rw.replicas))
+	for _, r := range rw.replicas {
+		if atomic.LoadInt32(&r.healthy) == 1 {
+			healthy = append(healthy, r)
+		}
+	}
+	if len(healthy) == 0 {
+		return nil
+	}
+	if rw.policy == ReplicaRoundRobin {
+		return healthy[int(atomic.AddUint32(&rw.next, 1)-1)%len(healthy)]
+	}
+	return healthy[rand.Intn(len(healthy))]
+}
+
+// checkLoop 定期检查副本
+func (rw *ReadWriteSplit) checkLoop() {
+	ticker := time.NewTicker(rw.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-rw.stop:
+			return
+		case <-ticker.C:
+			rw.check()
+		}
+	}
+}
+
+// check 检查全部副本，状态变化时输出日志
+func (rw *ReadWriteSplit) check() {
+	for _, r := range rw.replicas {
+		ctx, cancel := context.WithTimeout(context.Background(), DefaultPingTimeout)
+		err := r.DB.PingContext(ctx)
+		cancel()
+		if err != nil {
+			if atomic.CompareAndSwapInt32(&r.healthy, 1, 0) {
+				zaplog.SugaredLogger.Warnf("replica %s removed: %s", r.Name, err)
+			}
+		} else if atomic.CompareAndSwapInt32(&r.healthy, 0, 1) {
+			zaplog.SugaredLogger.Infof("replica %s re-added", r.Name)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package datasource
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"gorm.io/gorm/logger"
+)
+
+// node 统计查询、写入次数的数据库节点，down 为1时连接检查失败
+type node struct {
+	queries, execs, down int32
+}
+
+func (n *node) Connect(context.Context) (driver.Conn, error) { return nodeConn{n}, nil }
+func (n *node) Driver() driver.Driver                        { return fake }
+
+type nodeConn struct{ n *node }
+
+func (nodeConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("not supported") }
+func (nodeConn) Close() error                        { return nil }
+func (nodeConn) Begin() (driver.Tx, error)           { return nodeTx{}, nil }
+
+func (c nodeConn) Ping(context.Context) error {
+	if atomic.LoadInt32(&c.n.down) == 1 {
+		return errors.New("connection refused")
+	}
+	return nil
+}
+
+func (c nodeConn) QueryContext(context.Context, string, []driver.NamedValue) (driver.Rows, error) {
+	atomic.AddInt32(&c.n.queries, 1)
+	return &fakeRows{rows: fake.rows}, nil
+}
+
+func (c nodeConn) ExecContext(context.Context, string, []driver.NamedValue) (driver.Result, error) {
+	atomic.AddInt32(&c.n.execs, 1)
+	return fakeResult{}, nil
+}
+
+type nodeTx struct{}
+
+func (nodeTx) Commit() error   { return nil }
+func (nodeTx) Rollback() error { return nil }
+
+func newSplitDb(t *testing.T, policy string) (*gorm.DB, *node, []*node) {
+	primary, replicas := &node{}, []*node{{}, {}}
+	db, err := gorm.Open(postgres.New(postgres.Config{Conn: sql.OpenDB(primary), WithoutReturning: true}), &gorm.Config{
+		Logger:                 logger.Discard,
+		SkipDefaultTransaction: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rw := NewReadWriteSplit(policy, 10*time.Millisecond,
+		Replica{Name: "replica-1", DB: sql.OpenDB(replicas[0])},
+		Replica{Name: "replica-2", DB: sql.OpenDB(replicas[1])})
+	if err = db.Use(rw); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = rw.Close() })
+	return db, primary, replicas
+}
+
+func TestReadWriteSplit(t *testing.T) {
+	db, primary, replicas := newSplitDb(t, ReplicaRoundRobin)
+
+	for i := 0; i < 4; i++ {
+		var heroes []hero
+		db.Find(&heroes)
+	}
+	if primary.queries != 0 || replicas[0].queries != 2 || replicas[1].queries != 2 {
+		t.Errorf("reads should be balanced across replicas, primary %d replicas %d %d",
+			primary.queries, replicas[0].queries, replicas[1].queries)
+	}
+
+	db.Create(&hero{Name: "Starlight"})
+	db.Exec("UPDATE hero SET name = ?", "Maeve")
+	var name string
+	db.Raw("UPDATE hero SET name = ? RETURNING name", "Noir").Row().Scan(&name)
+	if primary.execs != 2 || primary.queries != 1 {
+		t.Errorf("writes should go to primary, execs %d queries %d", primary.execs, primary.queries)
+	}
+
+	// 事务及 UsePrimary 标记的查询使用主库
+	_ = db.Transaction(func(tx *gorm.DB) error {
+		var h hero
+		return tx.First(&h).Error
+	})
+	var h hero
+	db.WithContext(UsePrimary(context.Background())).First(&h)
+	db.Raw("SELECT * FROM hero").Scan(&h)
+	if primary.queries != 3 || replicas[0].queries+replicas[1].queries != 5 {
+		t.Errorf("transaction and UsePrimary should read primary, primary %d replicas %d",
+			primary.queries, replicas[0].queries+replicas[1].queries)
+	}
+}
+
+func TestReadWriteSplitPrimary(t *testing.T) {
+	db, primary, replicas := newSplitDb(t, ReplicaRoundRobin)
+
+	// 复用同一语句先查询再更新，更新使用主库
+	var heroes []hero
+	q := db.Model(&hero{}).Where("id = ?", 1)
+	q.Find(&heroes)
+	q.Update("name", "x")
+	if primary.execs != 1 || replicas[0].execs+replicas[1].execs != 0 {
+		t.Errorf("update after read on reused statement should go to primary, primary %d replicas %d",
+			primary.execs, replicas[0].execs+replicas[1].execs)
+	}
+	q.Find(&heroes)
+	if primary.queries != 0 || replicas[0].queries+replicas[1].queries != 2 {
+		t.Errorf("read after update should go to replica again, primary %d", primary.queries)
+	}
+
+	// 加锁读使用主库
+	var h hero
+	db.Clauses(clause.Locking{Strength: "UPDATE"}).First(&h)
+	db.Raw("SELECT * FROM hero WHERE id = ? FOR UPDATE", 1).Scan(&h)
+	db.Raw("SELECT * FROM hero LOCK IN SHARE MODE").Scan(&h)
+	if primary.queries != 3 {
+		t.Errorf("locking reads should go to primary but get %d", primary.queries)
+	}
+}
+
+func TestReplicaHealth(t *testing.T) {
+	db, primary, replicas := newSplitDb(t, ReplicaRandom)
+	waitFor := func(cond func() bool) {
+		deadline := time.Now().Add(time.Second)
+		for !cond() && time.Now().Before(deadline) {
+			var heroes []hero
+			db.Find(&heroes)
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+
+	// 副本1不可用后不再路由
+	atomic.StoreInt32(&replicas[0].down, 1)
+	time.Sleep(50 * time.Millisecond)
+	before := atomic.LoadInt32(&replicas[0].queries)
+	for i := 0; i < 10; i++ {
+		var heroes []hero
+		db.Find(&heroes)
+	}
+	if atomic.LoadInt32(&replicas[0].queries) != before {
+		t.Error("unhealthy replica should be removed")
+	}
+
+	// 全部不可用时回退到主库
+	atomic.StoreInt32(&replicas[1].down, 1)
+	waitFor(func() bool { return atomic.LoadInt32(&primary.queries) > 0 })
+	if atomic.LoadInt32(&primary.queries) == 0 {
+		t.Error("reads should fall back to primary when no replica is healthy")
+	}
+
+	// 恢复后重新加入
+	atomic.StoreInt32(&replicas[0].down, 0)
+	waitFor(func() bool { return atomic.LoadInt32(&replicas[0].queries) > before })
+	if atomic.LoadInt32(&replicas[0].queries) == before {
+		t.Error("recovered replica should be re-added")
+	}
+}
@@ -0,0 +1,208 @@
+package datasource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+/**
+* @Description: 通用增删改查，T 为嵌入 model.Model 的结构体，按主键 id 操作，删除为逻辑删除
+ */
+
+// 分页参数
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 1000
+)
+
+// ErrInvalidSort 排序字段不合法
+var ErrInvalidSort = errors.New("datasource: invalid sort")
+
+// sortPattern 排序格式 column [asc|desc]
+var sortPattern = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_.]*)(?:\s+(?i:(asc|desc)))?$`)
+
+// Filter 查询条件，参数与 gorm Where 一致
+type Filter struct {
+	Query interface{}
+	Args  []interface{}
+}
+
+// Where 创建查询条件，如 Where("name LIKE ?", "%a%")、Where(map[string]interface{}{"status": 1})
+func Where(query interface{}, args ...interface{}) Filter {
+	return Filter{Query: query, Args: args}
+}
+
+// ListOptions 列表查询参数
+type ListOptions struct {
+	Filters  []Filter
+	Sort     []string // 排序，如 "created_at desc"，默认按 id 升序
+	Page     int      // 页码，从1开始，0不分页
+	Size     int      // 每页条数，默认20，最大1000
+	Unscoped bool     // 包含已删除的记录
+}
+
+// PageResult 分页查询结果
+type PageResult[T any] struct {
+	Items []T   `json:"items"`
+	Total int64 `json:"total"`
+	Page  int   `json:"page"`
+	Size  int   `json:"size"`
+}
+
+// Repository T的通用数据访问
+type Repository[T any] struct {
+	db *gorm.DB
+}
+
+// NewRepository 创建数据访问对象
+func NewRepository[T any](db *gorm.DB) *Repository[T] {
+	return &Repository[T]{db: db}
+}
+
+// WithTx 绑定到已开启的事务
+func (r *Repository[T]) WithTx(tx *gorm.DB) *Repository[T] {
+	return &Repository[T]{db: tx}
+}
+
+// Transaction 在事务中执行，fn 返回错误时回滚
+func (r *Repository[T]) Transaction(ctx context.Context, fn func(repo *Repository[T]) error) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return fn(r.WithTx(tx))
+	})
+}
+
+// Create 新增记录，主键及创建时间回写到entity
+func (r *Repository[T]) Create(ctx context.Context, entity *T) error {
+	return r.db.WithContext(ctx).Create(entity).Error
+}
+
+// Get 按主键查询，不存在时返回 gorm.ErrRecordNotFound
+func (r *Repository[T]) Get(ctx context.Context, id interface{}) (*T, error) {
+	entity := new(T)
+	if err := r.db.WithContext(ctx).First(entity, "id = ?", id).Error; err != nil {
+		return nil, err
+	}
+	return entity, nil
+}
+
+// Update 按主键更新全部字段（包括零值），创建时间不变，不存在时返回 gorm.ErrRecordNotFound
+func (r *Repository[T]) Update(ctx context.Context, entity *T) error {
+	tx := r.db.WithContext(ctx).Model(entity).Select("*").Omit("id", "created_at", "deleted_at").Updates(entity)
+	return affected(tx)
+}
+
+// UpdateFields 按主键更新指定字段，key 为列名，不存在时返回 gorm.ErrRecordNotFound
+func (r *Repository[T]) UpdateFields(ctx context.Context, id interface{}, fields map[string]interface{}) error {
+	tx := r.db.WithContext(ctx).Model(new(T)).Where("id = ?", id).Updates(fields)
+	return affected(tx)
+}
+
+// Delete 逻辑删除，不存在时返回 gorm.ErrRecordNotFound
+func (r *Repository[T]) Delete(ctx context.Context, id interface{}) error {
+	return affected(r.db.WithContext(ctx).Delete(new(T), "id = ?", id))
+}
+
+// Restore 恢复逻辑删除的记录，记录不存在或未删除时返回 gorm.ErrRecordNotFound
+func (r *Repository[T]) Restore(ctx context.Context, id interface{}) error {
+	tx := r.db.WithContext(ctx).Unscoped().Model(new(T)).
+		Where("id = ? AND deleted_at IS NOT NULL", id).Update("deleted_at", nil)
+	return affected(tx)
+}
+
+// Purge 物理删除，包括已逻辑删除的记录，不存在时返回 gorm.ErrRecordNotFound
+func (r *Repository[T]) Purge(ctx context.Context, id interface{}) error {
+	return affected(r.db.WithContext(ctx).Unscoped().Delete(new(T), "id = ?", id))
+}
+
+// List 按条件、排序分页查询
+func (r *Repository[T]) List(ctx context.Context, opts ListOptions) (*PageResult[T], error) {
+	tx := r.query(ctx, opts.Unscoped, opts.Filters)
+	orders, err := orderBy(opts.Sort)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &PageResult[T]{Items: make([]T, 0), Page: opts.Page}
+	if opts.Page > 0 {
+		result.Size = opts.Size
+		if result.Size <= 0 {
+			result.Size = DefaultPageSize
+		} else if result.Size > MaxPageSize {
+			result.Size = MaxPageSize
+		}
+		if err = tx.Session(&gorm.Session{}).Count(&result.Total).Error; err != nil {
+			return nil, err
+		}
+		tx = tx.Offset((opts.Page - 1) * result.Size).Limit(result.Size)
+	}
+	if err = tx.Clauses(orders).Find(&result.Items).Error; err != nil {
+		return nil, err
+	}
+	if opts.Page <= 0 {
+		result.Total, result.Size = int64(len(result.Items)), len(result.Items)
+	}
+	return result, nil
+}
+
+// Count 统计满足条件的记录数
+func (r *Repository[T]) Count(ctx context.Context, filters ...Filter) (total int64, err error) {
+	err = r.query(ctx, false, filters).Count(&total).Error
+	return
+}
+
+// Exists 是否存在满足条件的记录
+func (r *Repository[T]) Exists(ctx context.Context, filters ...Filter) (bool, error) {
+	var found []int64
+	if err := r.query(ctx, false, filters).Limit(1).Pluck("id", &found).Error; err != nil {
+		return false, err
+	}
+	return len(found) > 0, nil
+}
+
+// query 带条件的查询
+func (r *Repository[T]) query(ctx context.Context, unscoped bool, filters []Filter) *gorm.DB {
+	tx := r.db.WithContext(ctx).Model(new(T))
+	if unscoped {
+		tx = tx.Unscoped()
+	}
+	for _, f := range filters {
+		tx = tx.Where(f.Query, f.Args...)
+	}
+	return tx
+}
+
+// orderBy 解析排序，列名只允许字母、数字、下划线，防止SQL注入
+func orderBy(sort []string) (clause.OrderBy, error) {
+	orders := clause.OrderBy{}
+	for _, s := range sort {
+		match := sortPattern.FindStringSubmatch(strings.TrimSpace(s))
+		if match == nil {
+			return orders, fmt.Errorf("%w: %s", ErrInvalidSort, s)
+		}
+		orders.Columns = append(orders.Columns, clause.OrderByColumn{
+			Column: clause.Column{Name: match[1]},
+			Desc:   strings.EqualFold(match[2], "desc"),
+		})
+	}
+	if len(orders.Columns) == 0 {
+		orders.Columns = append(orders.Columns, clause.OrderByColumn{Column: clause.Column{Name: "id"}})
+	}
+	return orders, nil
+}
+
+// affected 执行结果没有影响任何记录时返回 gorm.ErrRecordNotFound
+func affected(tx *gorm.DB) error {
+	if tx.Error != nil {
+		return tx.Error
+	}
+	if tx.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package datasource
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+
+	"github.com/Domingor/go-blackbox/server/datasource/model"
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+type villain struct {
+	model.Model
+	Name  string
+	Power int
+}
+
+func newRepository(t *testing.T) *Repository[villain] {
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "app.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = db.AutoMigrate(&villain{}); err != nil {
+		t.Fatal(err)
+	}
+	return NewRepository[villain](db)
+}
+
+func TestRepository(t *testing.T) {
+	ctx := context.Background()
+	repo := newRepository(t)
+
+	v := &villain{Name: "Homelander", Power: 10}
+	if err := repo.Create(ctx, v); err != nil || v.ID == 0 {
+		t.Fatalf("create failed %+v %v", v, err)
+	}
+	v.Name, v.Power = "Soldier Boy", 0
+	if err := repo.Update(ctx, v); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := repo.Get(ctx, v.ID); err != nil || got.Name != "Soldier Boy" || got.Power != 0 || got.CreatedAt.IsZero() {
+		t.Errorf("full update should write zero values and keep created_at but get %+v %v", got, err)
+	}
+	if err := repo.UpdateFields(ctx, v.ID, map[string]interface{}{"power": 8}); err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := repo.Get(ctx, v.ID); got.Power != 8 || got.Name != "Soldier Boy" {
+		t.Errorf("partial update should only change given fields but get %+v", got)
+	}
+	if err := repo.UpdateFields(ctx, 404, map[string]interface{}{"power": 1}); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Errorf("want ErrRecordNotFound but get %v", err)
+	}
+
+	// 逻辑删除、恢复、物理删除
+	if err := repo.Delete(ctx, v.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Get(ctx, v.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Error("deleted record should not be found")
+	}
+	if page, _ := repo.List(ctx, ListOptions{Unscoped: true}); page.Total != 1 {
+		t.Error("unscoped list should include deleted record")
+	}
+	if err := repo.Restore(ctx, v.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Restore(ctx, v.ID); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Error("restore record not deleted should fail")
+	}
+	if err := repo.Purge(ctx, v.ID); err != nil {
+		t.Fatal(err)
+	}
+	if page, _ := repo.List(ctx, ListOptions{Unscoped: true}); page.Total != 0 {
+		t.Error("purged record should be removed")
+	}
+}
+
+func TestRepositoryList(t *testing.T) {
+	ctx := context.Background()
+	repo := newRepository(t)
+	for i, name := range []string{"Stormfront", "Black Noir", "The Deep", "A-Train", "Translucent"} {
+		_ = repo.Create(ctx, &villain{Name: name, Power: i})
+	}
+	_ = repo.Delete(ctx, 5)
+
+	page, err := repo.List(ctx, ListOptions{
+		Filters: []Filter{Where("power >= ?", 1)},
+		Sort:    []string{"name DESC"},
+		Page:    2,
+		Size:    2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.Total != 3 || page.Size != 2 || len(page.Items) != 1 || page.Items[0].Name != "A-Train" {
+		t.Errorf("unexpected page %+v", page)
+	}
+	if page, _ = repo.List(ctx, ListOptions{}); page.Total != 4 || page.Items[0].Name != "Stormfront" {
+		t.Errorf("list without page should return all sorted by id but get %+v", page)
+	}
+	if _, err = repo.List(ctx, ListOptions{Sort: []string{"name; DROP TABLE villain"}}); !errors.Is(err, ErrInvalidSort) {
+		t.Errorf("want ErrInvalidSort but get %v", err)
+	}
+
+	if n, _ := repo.Count(ctx, Where(map[string]interface{}{"name": "The Deep"})); n != 1 {
+		t.Errorf("want 1 but get %d", n)
+	}
+	if ok, _ := repo.Exists(ctx, Where("name = ?", "Translucent")); ok {
+		t.Error("deleted record should not exist")
+	}
+	if ok, _ := repo.Exists(ctx, Where("name LIKE ?", "%Noir")); !ok {
+		t.Error("record should exist")
+	}
+}
+
+func TestRepositoryTransaction(t *testing.T) {
+	ctx := context.Background()
+	repo := newRepository(t)
+
+	err := repo.Transaction(ctx, func(tx *Repository[villain]) error {
+		if err := tx.Create(ctx, &villain{Name: "Stan Edgar"}); err != nil {
+			return err
+		}
+		return errors.New("rollback")
+	})
+	if n, _ := repo.Count(ctx); err == nil || n != 0 {
+		t.Errorf("failed transaction should rollback but get %d %v", n, err)
+	}
+
+	_ = repo.db.Transaction(func(tx *gorm.DB) error {
+		return repo.WithTx(tx).Create(ctx, &villain{Name: "Victoria Neuman"})
+	})
+	if ok, _ := repo.Exists(ctx, Where("name = ?", "Victoria Neuman")); !ok {
+		t.Error("record created in bound transaction should be committed")
+	}
+}
@@ -0,0 +1,135 @@
+package datasource
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Domingor/go-blackbox/server/zaplog"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+/**
+* @Description: AutoMigrate 试运行，对比model与数据库当前表结构，记录将要执行的DDL而不实际执行；
+* 按 SchemaPolicy 决定启动时自动执行、只输出差异或存在差异时拒绝启动
+ */
+
+// 表结构差异处理策略
+const (
+	SchemaAuto   = "auto"   // 自动执行 AutoMigrate，默认
+	SchemaLog    = "log"    // 只输出差异，不修改表结构
+	SchemaRefuse = "refuse" // 存在差异时拒绝启动
+)
+
+// ErrSchemaDrift model与数据库表结构不一致
+var ErrSchemaDrift = errors.New("datasource: schema drift detected")
+
+// Diff 试运行 AutoMigrate，返回使model与数据库表结构一致需要执行的DDL，不修改数据库。
+// 查询表结构的语句正常执行，其余语句只记录；同一次对比中后续语句基于当前表结构生成，结果与实际执行可能略有差异
+func Diff(db *gorm.DB, models ...interface{}) ([]string, error) {
+	tx := db.Session(&gorm.Session{NewDB: true, Logger: db.Logger.LogMode(logger.Silent)})
+	tx = tx.WithContext(UsePrimary(context.Background()))
+	recorder := &ddlRecorder{ConnPool: tx.Statement.ConnPool, dialector: db.Dialector}
+	tx.Statement.ConnPool = recorder
+	if err := tx.AutoMigrate(models...); err != nil {
+		return nil, err
+	}
+	return recorder.statements, nil
+}
+
+// WriteDiff 以SQL文件格式输出DDL
+func WriteDiff(w io.Writer, alias string, statements []string) error {
+	var b strings.Builder
+	fmt.Fprintf(&b, "-- schema diff of db %s generated at %s\n", alias, time.Now().Format("2006-01-02 15:04:05"))
+	for _, statement := range statements {
+		b.WriteString(strings.TrimRight(strings.TrimSpace(statement), ";"))
+		b.WriteString(";\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// migrateModels 按策略初始化model表，配置 SchemaDiffFile 时存在差异写入该文件
+func migrateModels(_db *gorm.DB, config *DataSourceConfig, models []interface{}) error {
+	policy := config.SchemaPolicy
+	if policy == "" {
+		policy = SchemaAuto
+	}
+	if policy != SchemaAuto && policy != SchemaLog && policy != SchemaRefuse {
+		return fmt.Errorf("datasource: unknown schema policy %s", policy)
+	}
+	if policy == SchemaAuto && config.SchemaDiffFile == "" {
+		return _db.AutoMigrate(models...)
+	}
+
+	statements, err := Diff(_db, models...)
+	if err != nil {
+		return err
+	}
+	alias := config.Alias()
+	if len(statements) > 0 {
+		zaplog.SugaredLogger.Warnf("db %s schema differs from models:\n%s", alias, strings.Join(statements, ";\n"))
+		if config.SchemaDiffFile != "" {
+			if err = writeDiffFile(config.SchemaDiffFile, alias, statements); err != nil {
+				return err
+			}
+		}
+	}
+
+	switch {
+	case policy == SchemaAuto:
+		return _db.AutoMigrate(models...)
+	case policy == SchemaRefuse && len(statements) > 0:
+		return fmt.Errorf("%w: db %s needs %d statement(s)", ErrSchemaDrift, alias, len(statements))
+	}
+	return nil
+}
+
+func writeDiffFile(path, alias string, statements []string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err = WriteDiff(file, alias, statements); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// ddlRecorder 记录执行语句的连接池，查询语句使用原连接池
+type ddlRecorder struct {
+	gorm.ConnPool
+	dialector  gorm.Dialector
+	mu         sync.Mutex
+	statements []string
+}
+
+func (r *ddlRecorder) ExecContext(_ context.Context, query string, args ...interface{}) (sql.Result, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.statements = append(r.statements, r.dialector.Explain(query, args...))
+	return recordedResult{}, nil
+}
+
+// BeginTx 迁移器在事务中执行的语句同样只记录
+func (r *ddlRecorder) BeginTx(context.Context, *sql.TxOptions) (gorm.ConnPool, error) {
+	return recordedTx{r}, nil
+}
+
+type recordedTx struct{ *ddlRecorder }
+
+func (recordedTx) Commit() error   { return nil }
+func (recordedTx) Rollback() error { return nil }
+
+type recordedResult struct{}
+
+func (recordedResult) LastInsertId() (int64, error) { return 0, nil }
+func (recordedResult) RowsAffected() (int64, error) { return 0, nil }
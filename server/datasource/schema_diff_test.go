@@ -0,0 +1,84 @@
+package datasource
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// heroV2 新增字段后的 hero
+type heroV2 struct {
+	ID    int64
+	Name  string
+	Power string
+}
+
+func (heroV2) TableName() string { return "hero" }
+
+// sidekick 新增的表
+type sidekick struct {
+	ID   int64
+	Name string
+}
+
+func TestDiff(t *testing.T) {
+	resetDataSources(t)
+	dir := t.TempDir()
+	config := &DataSourceConfig{Driver: DriverSqlite, DbName: filepath.Join(dir, "app.db")}
+	db, err := Open(config, &hero{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	statements, err := Diff(db, &heroV2{}, &sidekick{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statements) != 2 || !strings.Contains(statements[0], "ADD `power` text") ||
+		!strings.HasPrefix(statements[1], "CREATE TABLE `sidekick`") {
+		t.Fatalf("unexpected statements %q", statements)
+	}
+	if db.Migrator().HasColumn(&heroV2{}, "Power") || db.Migrator().HasTable(&sidekick{}) {
+		t.Error("dry run should not change schema")
+	}
+	if statements, _ = Diff(db, &hero{}); len(statements) != 0 {
+		t.Errorf("unchanged model should have no diff but get %q", statements)
+	}
+	_ = Close(DefaultAlias)
+
+	// refuse 存在差异时拒绝启动并输出SQL文件
+	config.SchemaPolicy, config.SchemaDiffFile = SchemaRefuse, filepath.Join(dir, "diff.sql")
+	if _, err = Open(config, &heroV2{}); !errors.Is(err, ErrSchemaDrift) {
+		t.Fatalf("want ErrSchemaDrift but get %v", err)
+	}
+	content, _ := os.ReadFile(config.SchemaDiffFile)
+	if !strings.Contains(string(content), "-- schema diff of db default") || !strings.Contains(string(content), "`power` text;\n") {
+		t.Errorf("unexpected diff file\n%s", content)
+	}
+
+	// log 只输出差异
+	config.SchemaPolicy = SchemaLog
+	if db, err = Open(config, &heroV2{}); err != nil {
+		t.Fatal(err)
+	}
+	if db.Migrator().HasColumn(&heroV2{}, "Power") {
+		t.Error("log policy should not change schema")
+	}
+	_ = Close(DefaultAlias)
+
+	config.SchemaPolicy = SchemaAuto
+	if db, err = Open(config, &heroV2{}); err != nil {
+		t.Fatal(err)
+	}
+	if !db.Migrator().HasColumn(&heroV2{}, "Power") {
+		t.Error("auto policy should migrate schema")
+	}
+	_ = Close(DefaultAlias)
+
+	config.SchemaPolicy = "never"
+	if _, err = Open(config, &heroV2{}); err == nil {
+		t.Error("unknown policy should fail")
+	}
+}
@@ -1,8 +1,8 @@
 package email
 
 type MailConnConf struct {
-	User  string // 发送人邮箱（邮箱以自己的为准）
-	Pass  string // 发送人邮箱的密码，现在可能会需要邮箱 开启授权密码后在pass填写授权码
-	Host  string // 邮箱服务器
-	Alias string // 邮箱发送别名
+	User  string `mapstructure:"user" json:"user" yaml:"user"`    // 发送人邮箱（邮箱以自己的为准）
+	Pass  string `mapstructure:"pass" json:"pass" yaml:"pass"`    // 发送人邮箱的密码，现在可能会需要邮箱 开启授权密码后在pass填写授权码
+	Host  string `mapstructure:"host" json:"host" yaml:"host"`    // 邮箱服务器
+	Alias string `mapstructure:"alias" json:"alias" yaml:"alias"` // 邮箱发送别名
 }
@@ -0,0 +1,297 @@
+package lock
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/Domingor/go-blackbox/server/cache"
+	"github.com/Domingor/go-blackbox/server/zaplog"
+	"github.com/redis/go-redis/v9"
+)
+
+/**
+* @Description: 基于redis的分布式锁，支持随机token、原子释放、自动续期、等待加锁和fencing计数
+ */
+
+const (
+	// DefaultPrefix 锁key前缀
+	DefaultPrefix = "go-blackbox:lock:"
+	// retryInterval TryLock 重试间隔
+	retryInterval = 50 * time.Millisecond
+)
+
+var (
+	// ErrNotAcquired 锁已被其他持有者占用
+	ErrNotAcquired = errors.New("lock: not acquired")
+	// ErrNotHeld 锁已过期或被其他持有者获取
+	ErrNotHeld = errors.New("lock: not held")
+)
+
+// 加锁：SET NX PX 成功后递增fencing计数
+var acquireScript = redis.NewScript(`
+if redis.call("set", KEYS[1], ARGV[1], "NX", "PX", ARGV[2]) then
+	return redis.call("incr", KEYS[2])
+end
+return 0
+`)
+
+// 释放：token一致才删除，避免误删其他持有者的锁
+var releaseScript = redis.NewScript(`
+if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("del", KEYS[1])
+end
+return 0
+`)
+
+// 续期：token一致才重新设置过期时间
+var refreshScript = redis.NewScript(`
+if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("pexpire", KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// Locker 分布式锁客户端
+type Locker struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// New 创建分布式锁客户端
+func New(client redis.UniversalClient) *Locker {
+	return &Locker{client: client, prefix: DefaultPrefix}
+}
+
+// FromCache 基于缓存的redis客户端创建分布式锁，内存缓存不支持分布式锁
+func FromCache(r cache.Rediser) (*Locker, error) {
+	rc, ok := r.(*cache.RedisCache)
+	if !ok || rc == nil {
+		return nil, fmt.Errorf("lock: redis cache is required, got %T", r)
+	}
+	return New(rc.GetUniversalClient()), nil
+}
+
+// WithPrefix 设置锁key前缀
+func (l *Locker) WithPrefix(prefix string) *Locker {
+	l.prefix = prefix
+	return l
+}
+
+// Lock 已获取的锁，持有期间自动续期，直到 Release 或加锁时传入的ctx结束
+type Lock struct {
+	locker *Locker
+	key    string
+	token  string
+	fence  int64
+	ttl    time.Duration
+
+	stop     context.CancelFunc
+	done     chan struct{} // 续期协程退出
+	lost     chan struct{} // 锁丢失通知
+	lostOnce sync.Once
+}
+
+// Acquire 尝试加锁一次，锁被占用时返回 ErrNotAcquired
+func (l *Locker) Acquire(ctx context.Context, key string, ttl time.Duration) (*Lock, error) {
+	if ttl <= 0 {
+		return nil, fmt.Errorf("lock: ttl must be positive")
+	}
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+
+	lockKey, fenceKey := l.keys(key)
+	fence, err := acquireScript.Run(ctx, l.client, []string{lockKey, fenceKey}, token, ttl.Milliseconds()).Int64()
+	if err != nil {
+		return nil, err
+	}
+	if fence == 0 {
+		return nil, ErrNotAcquired
+	}
+
+	renewCtx, stop := context.WithCancel(context.Background())
+	lk := &Lock{
+		locker: l,
+		key:    lockKey,
+		token:  token,
+		fence:  fence,
+		ttl:    ttl,
+		stop:   stop,
+		done:   make(chan struct{}),
+		lost:   make(chan struct{}),
+	}
+	go lk.renew(ctx, renewCtx)
+	return lk, nil
+}
+
+// TryLock 在wait时间内反复尝试加锁，超时返回 ErrNotAcquired
+func (l *Locker) TryLock(ctx context.Context, key string, ttl, wait time.Duration) (*Lock, error) {
+	deadline := time.NewTimer(wait)
+	defer deadline.Stop()
+
+	for {
+		lk, err := l.Acquire(ctx, key, ttl)
+		if !errors.Is(err, ErrNotAcquired) {
+			return lk, err
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-deadline.C:
+			return nil, ErrNotAcquired
+		case <-time.After(retryInterval):
+		}
+	}
+}
+
+// WithLock 加锁后执行fn，锁丢失时取消fn的ctx，执行结束后释放锁；适用于定时任务、种子函数
+func (l *Locker) WithLock(ctx context.Context, key string, ttl time.Duration, fn func(ctx context.Context) error) error {
+	lk, err := l.Acquire(ctx, key, ttl)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := lk.Release(context.Background()); err != nil && !errors.Is(err, ErrNotHeld) {
+			zaplog.SugaredLogger.Warnf("release lock %s failed: %s", key, err)
+		}
+	}()
+
+	fnCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-lk.Lost():
+			cancel()
+		case <-fnCtx.Done():
+		}
+	}()
+	return fn(fnCtx)
+}
+
+// Key 锁在redis中的key
+func (lk *Lock) Key() string {
+	return lk.key
+}
+
+// Token 本次加锁的随机token
+func (lk *Lock) Token() string {
+	return lk.token
+}
+
+// Fence fencing计数，每次加锁单调递增；写入下游存储时携带该值，可拒绝过期持有者的写入
+func (lk *Lock) Fence() int64 {
+	return lk.fence
+}
+
+// Lost 锁丢失时关闭：锁被删除或被其他持有者获取、ttl内没有一次续期成功、加锁时传入的ctx结束
+func (lk *Lock) Lost() <-chan struct{} {
+	return lk.lost
+}
+
+// Refresh 手动续期
+func (lk *Lock) Refresh(ctx context.Context, ttl time.Duration) error {
+	ok, err := refreshScript.Run(ctx, lk.locker.client, []string{lk.key}, lk.token, ttl.Milliseconds()).Int64()
+	if err != nil {
+		return err
+	}
+	if ok == 0 {
+		lk.markLost()
+		return ErrNotHeld
+	}
+	return nil
+}
+
+// Release 停止续期并释放锁，锁已不属于当前持有者时返回 ErrNotHeld
+func (lk *Lock) Release(ctx context.Context) error {
+	lk.stop()
+	<-lk.done
+
+	ok, err := releaseScript.Run(ctx, lk.locker.client, []string{lk.key}, lk.token).Int64()
+	if err != nil {
+		return err
+	}
+	if ok == 0 {
+		return ErrNotHeld
+	}
+	return nil
+}
+
+// Hold 停止续期，锁继续保留d时长后自动过期；用于任务执行结束后仍需短暂占用锁的场景，d<=0时立即释放
+func (lk *Lock) Hold(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return lk.Release(ctx)
+	}
+	lk.stop()
+	<-lk.done
+	return lk.Refresh(ctx, d)
+}
+
+// renew 每 ttl/3 续期一次，直到 Release/Hold 停止续期；
+// 锁不再属于当前持有者、下次续期前锁会过期（连续续期失败）或加锁ctx结束时标记锁丢失并退出
+func (lk *Lock) renew(ctx, stopped context.Context) {
+	defer close(lk.done)
+
+	interval := lk.ttl / 3
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	lastRenewed := time.Now()
+	for {
+		select {
+		case <-stopped.Done():
+			return
+		case <-ctx.Done():
+			zaplog.SugaredLogger.Warnf("lock %s lost: %s", lk.key, ctx.Err())
+			lk.markLost()
+			return
+		case <-ticker.C:
+			refreshCtx, cancel := context.WithTimeout(stopped, interval)
+			err := lk.Refresh(refreshCtx, lk.ttl)
+			cancel()
+			if errors.Is(err, ErrNotHeld) {
+				zaplog.SugaredLogger.Warnf("lock %s lost", lk.key)
+				return
+			}
+			if err == nil {
+				lastRenewed = time.Now()
+				continue
+			}
+			if stopped.Err() != nil {
+				return
+			}
+			// 锁的有效期从最后一次成功续期开始计算，等到下一次续期时可能已经被其他进程获取
+			if time.Since(lastRenewed)+interval >= lk.ttl {
+				zaplog.SugaredLogger.Warnf("lock %s lost: renew failed %s", lk.key, err)
+				lk.markLost()
+				return
+			}
+			zaplog.SugaredLogger.Warnf("renew lock %s failed: %s", lk.key, err)
+		}
+	}
+}
+
+func (lk *Lock) markLost() {
+	lk.lostOnce.Do(func() {
+		close(lk.lost)
+	})
+}
+
+// keys 锁key和fencing计数key，使用hash tag保证集群模式下位于同一个slot
+func (l *Locker) keys(key string) (lockKey, fenceKey string) {
+	lockKey = l.prefix + "{" + key + "}"
+	return lockKey, lockKey + ":fence"
+}
+
+// newToken 生成随机token
+func newToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,208 @@
+package lock
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Domingor/go-blackbox/server/cache"
+	"github.com/Domingor/go-blackbox/server/zaplog"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+func TestMain(m *testing.M) {
+	zaplog.Logger = zap.NewNop()
+	zaplog.SugaredLogger = zaplog.Logger.Sugar()
+	os.Exit(m.Run())
+}
+
+func newLocker(t *testing.T) (*miniredis.Miniredis, *Locker) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	return server, New(client)
+}
+
+func TestAcquireRelease(t *testing.T) {
+	ctx := context.Background()
+	_, locker := newLocker(t)
+
+	lk, err := locker.Acquire(ctx, "job", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lk.Fence() != 1 || lk.Token() == "" {
+		t.Errorf("unexpected fence %d token %q", lk.Fence(), lk.Token())
+	}
+	if _, err = locker.Acquire(ctx, "job", time.Second); !errors.Is(err, ErrNotAcquired) {
+		t.Errorf("second acquire want ErrNotAcquired but get %v", err)
+	}
+	if err = lk.Release(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	lk2, err := locker.Acquire(ctx, "job", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lk2.Release(ctx)
+	if lk2.Fence() != 2 {
+		t.Errorf("fence should increase, want 2 but get %d", lk2.Fence())
+	}
+	// 已释放的锁不能误删新的持有者
+	if err = lk.Release(ctx); !errors.Is(err, ErrNotHeld) {
+		t.Errorf("release stale lock want ErrNotHeld but get %v", err)
+	}
+}
+
+func TestAutoRenew(t *testing.T) {
+	ctx := context.Background()
+	server, locker := newLocker(t)
+
+	lk, err := locker.Acquire(ctx, "renew", 300*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lk.Release(ctx)
+
+	// miniredis 不会自动流逝时间：先推进200ms，等待续期把有效期重置为300ms，再推进200ms锁仍然存在
+	server.FastForward(200 * time.Millisecond)
+	time.Sleep(150 * time.Millisecond)
+	server.FastForward(200 * time.Millisecond)
+	if !server.Exists(lk.Key()) {
+		t.Error("lock should be renewed while held")
+	}
+}
+
+func TestLost(t *testing.T) {
+	ctx := context.Background()
+	server, locker := newLocker(t)
+
+	lk, err := locker.Acquire(ctx, "lost", 300*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.Del(lk.Key())
+
+	select {
+	case <-lk.Lost():
+	case <-time.After(time.Second):
+		t.Fatal("lost channel should be closed when lock is taken away")
+	}
+	if err = lk.Release(ctx); !errors.Is(err, ErrNotHeld) {
+		t.Errorf("release lost lock want ErrNotHeld but get %v", err)
+	}
+}
+
+func TestLostWhenRenewFails(t *testing.T) {
+	server, locker := newLocker(t)
+	lk, err := locker.Acquire(context.Background(), "outage", 300*time.Millisecond)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// redis不可用期间锁会过期，不能等到恢复后才发现锁已丢失
+	start := time.Now()
+	server.Close()
+	select {
+	case <-lk.Lost():
+		if elapsed := time.Since(start); elapsed > 300*time.Millisecond {
+			t.Errorf("lock should be lost before ttl expires but take %s", elapsed)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("lost channel should be closed when renew keeps failing")
+	}
+}
+
+func TestLostWhenContextDone(t *testing.T) {
+	_, locker := newLocker(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	lk, err := locker.Acquire(ctx, "cancel", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	select {
+	case <-lk.Lost():
+	case <-time.After(time.Second):
+		t.Fatal("lost channel should be closed when acquire ctx is done")
+	}
+
+	// Release 不标记锁丢失
+	lk2, _ := locker.Acquire(context.Background(), "release", time.Second)
+	_ = lk2.Release(context.Background())
+	select {
+	case <-lk2.Lost():
+		t.Error("released lock should not be reported as lost")
+	default:
+	}
+}
+
+func TestHold(t *testing.T) {
+	ctx := context.Background()
+	server, locker := newLocker(t)
+
+	lk, err := locker.Acquire(ctx, "hold", time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = lk.Hold(ctx, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = locker.Acquire(ctx, "hold", time.Second); !errors.Is(err, ErrNotAcquired) {
+		t.Errorf("held lock want ErrNotAcquired but get %v", err)
+	}
+	server.FastForward(6 * time.Second)
+	if _, err = locker.Acquire(ctx, "hold", time.Second); err != nil {
+		t.Errorf("lock should expire after hold but get %v", err)
+	}
+}
+
+func TestTryLock(t *testing.T) {
+	ctx := context.Background()
+	_, locker := newLocker(t)
+
+	lk, err := locker.Acquire(ctx, "wait", time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = locker.TryLock(ctx, "wait", time.Second, 100*time.Millisecond); !errors.Is(err, ErrNotAcquired) {
+		t.Errorf("trylock want ErrNotAcquired but get %v", err)
+	}
+
+	time.AfterFunc(100*time.Millisecond, func() { _ = lk.Release(ctx) })
+	lk2, err := locker.TryLock(ctx, "wait", time.Second, time.Second)
+	if err != nil {
+		t.Fatalf("trylock should acquire after release but get %v", err)
+	}
+	_ = lk2.Release(ctx)
+}
+
+func TestWithLock(t *testing.T) {
+	ctx := context.Background()
+	_, locker := newLocker(t)
+
+	ran := false
+	err := locker.WithLock(ctx, "with", time.Second, func(ctx context.Context) error {
+		ran = true
+		_, err := locker.Acquire(ctx, "with", time.Second)
+		return err
+	})
+	if !ran || !errors.Is(err, ErrNotAcquired) {
+		t.Errorf("fn should run while lock held, ran %v err %v", ran, err)
+	}
+	// 执行结束后锁被释放
+	if err = locker.WithLock(ctx, "with", time.Second, func(context.Context) error { return nil }); err != nil {
+		t.Errorf("lock should be released after WithLock but get %v", err)
+	}
+}
+
+func TestFromCache(t *testing.T) {
+	if _, err := FromCache(cache.NewMemoryCache(context.Background())); err == nil {
+		t.Error("memory cache should not support distributed lock")
+	}
+}
@@ -45,11 +45,11 @@ type RabbitMQ struct {
 
 // QueueExchange 定义队列交换机对象,外部可调用
 type QueueExchange struct {
-	QuName string // 队列名称
-	RtKey  string // key值
-	ExName string // 交换机名称
-	ExType string // 交换机类型
-	Dns    string //链接地址
+	QuName string `mapstructure:"queueName" json:"queueName" yaml:"queueName"`          // 队列名称
+	RtKey  string `mapstructure:"routingKey" json:"routingKey" yaml:"routingKey"`       // key值
+	ExName string `mapstructure:"exchangeName" json:"exchangeName" yaml:"exchangeName"` // 交换机名称
+	ExType string `mapstructure:"exchangeType" json:"exchangeType" yaml:"exchangeType"` // 交换机类型
+	Dns    string `mapstructure:"dns" json:"dns" yaml:"dns"`                            //链接地址
 }
 
 // MqConnect 获取连接给 RabbitMQ
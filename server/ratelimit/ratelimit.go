@@ -0,0 +1,205 @@
+package ratelimit
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/Domingor/go-blackbox/server/cache"
+	"github.com/redis/go-redis/v9"
+)
+
+/**
+* @Description: 基于redis的分布式限流，滑动窗口和令牌桶算法均以Lua脚本原子执行，时间取自redis服务端
+ */
+
+// Algorithm 限流算法
+type Algorithm string
+
+const (
+	SlidingWindow Algorithm = "sliding-window" // 滑动窗口日志，精确限制任意窗口内的请求数
+	TokenBucket   Algorithm = "token-bucket"   // 令牌桶，允许一定突发流量
+
+	// DefaultPrefix 限流key前缀
+	DefaultPrefix = "go-blackbox:ratelimit:"
+)
+
+// 脚本中使用TIME后还有写操作，redis 5/6 需要开启 replicate_commands
+
+// 滑动窗口：有序集合记录窗口内每次请求的时间(ms)
+// 返回 {是否允许, 剩余次数, 重试等待ms, 窗口重置ms}
+var slidingWindowScript = redis.NewScript(`
+redis.replicate_commands()
+local key = KEYS[1]
+local window = tonumber(ARGV[1])
+local limit = tonumber(ARGV[2])
+local t = redis.call("TIME")
+local now = t[1] * 1000 + math.floor(t[2] / 1000)
+
+redis.call("ZREMRANGEBYSCORE", key, "-inf", now - window)
+local count = redis.call("ZCARD", key)
+if count < limit then
+	redis.call("ZADD", key, now, now .. "-" .. ARGV[3])
+	redis.call("PEXPIRE", key, window)
+	local oldest = redis.call("ZRANGE", key, 0, 0, "WITHSCORES")
+	return {1, limit - count - 1, 0, tonumber(oldest[2]) + window - now}
+end
+
+local oldest = redis.call("ZRANGE", key, 0, 0, "WITHSCORES")
+local retry = tonumber(oldest[2]) + window - now
+return {0, 0, retry, retry}
+`)
+
+// 令牌桶：哈希记录剩余令牌数和上次补充时间(ms)，按经过的时间补充令牌
+// 返回 {是否允许, 剩余令牌, 重试等待ms, 补满等待ms}
+var tokenBucketScript = redis.NewScript(`
+redis.replicate_commands()
+local key = KEYS[1]
+local rate = tonumber(ARGV[1])
+local capacity = tonumber(ARGV[2])
+local t = redis.call("TIME")
+local now = t[1] * 1000 + math.floor(t[2] / 1000)
+
+local data = redis.call("HMGET", key, "tokens", "ts")
+local tokens = tonumber(data[1])
+local ts = tonumber(data[2])
+if tokens == nil then
+	tokens = capacity
+	ts = now
+end
+tokens = math.min(capacity, tokens + math.max(0, now - ts) * rate)
+
+local allowed = 0
+local retry = 0
+if tokens >= 1 then
+	allowed = 1
+	tokens = tokens - 1
+else
+	retry = math.ceil((1 - tokens) / rate)
+end
+
+local full = math.ceil((capacity - tokens) / rate)
+redis.call("HSET", key, "tokens", tostring(tokens), "ts", now)
+redis.call("PEXPIRE", key, math.max(full, 1))
+return {allowed, math.floor(tokens), retry, full}
+`)
+
+// Limit 限流规则：Period 时间内最多 Rate 次请求
+type Limit struct {
+	Rate      int64         `mapstructure:"rate" json:"rate" yaml:"rate"`                // 周期内允许的请求数
+	Period    time.Duration `mapstructure:"period" json:"period" yaml:"period"`          // 统计周期，最小1ms
+	Burst     int64         `mapstructure:"burst" json:"burst" yaml:"burst"`             // 令牌桶容量，默认等于Rate
+	Algorithm Algorithm     `mapstructure:"algorithm" json:"algorithm" yaml:"algorithm"` // 限流算法，默认滑动窗口
+}
+
+// PerSecond 每秒rate次
+func PerSecond(rate int64) Limit {
+	return Limit{Rate: rate, Period: time.Second}
+}
+
+// PerMinute 每分钟rate次
+func PerMinute(rate int64) Limit {
+	return Limit{Rate: rate, Period: time.Minute}
+}
+
+// Result 限流结果
+type Result struct {
+	Allowed    bool          // 是否放行
+	Limit      int64         // 周期内允许的请求数，令牌桶为桶容量
+	Remaining  int64         // 剩余可用次数
+	RetryAfter time.Duration // 被拒绝时需要等待的时间
+	ResetAfter time.Duration // 额度完全恢复的时间
+}
+
+// Limiter 分布式限流器
+type Limiter struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// New 创建限流器
+func New(client redis.UniversalClient) *Limiter {
+	return &Limiter{client: client, prefix: DefaultPrefix}
+}
+
+// FromCache 基于缓存的redis客户端创建限流器，内存缓存不支持
+func FromCache(r cache.Rediser) (*Limiter, error) {
+	rc, ok := r.(*cache.RedisCache)
+	if !ok || rc == nil {
+		return nil, fmt.Errorf("ratelimit: redis cache is required, got %T", r)
+	}
+	return New(rc.GetUniversalClient()), nil
+}
+
+// WithPrefix 设置限流key前缀
+func (l *Limiter) WithPrefix(prefix string) *Limiter {
+	l.prefix = prefix
+	return l
+}
+
+// Allow 消耗一次额度，返回是否放行
+func (l *Limiter) Allow(ctx context.Context, key string, limit Limit) (*Result, error) {
+	// 脚本按毫秒计算，周期小于1ms时窗口为0
+	if limit.Rate <= 0 || limit.Period < time.Millisecond {
+		return nil, fmt.Errorf("ratelimit: invalid limit %+v", limit)
+	}
+
+	var (
+		values []int64
+		err    error
+	)
+	capacity := limit.Rate
+	switch limit.Algorithm {
+	case TokenBucket:
+		burst := limit.Burst
+		if burst <= 0 {
+			burst = limit.Rate
+		}
+		capacity = burst
+		rate := float64(limit.Rate) / float64(limit.Period.Milliseconds())
+		values, err = tokenBucketScript.Run(ctx, l.client, []string{l.key(TokenBucket, key)},
+			strconv.FormatFloat(rate, 'f', -1, 64), burst).Int64Slice()
+	case SlidingWindow, "":
+		var member string
+		if member, err = randomMember(); err != nil {
+			return nil, err
+		}
+		values, err = slidingWindowScript.Run(ctx, l.client, []string{l.key(SlidingWindow, key)},
+			limit.Period.Milliseconds(), limit.Rate, member).Int64Slice()
+	default:
+		return nil, fmt.Errorf("ratelimit: unknown algorithm %s", limit.Algorithm)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Result{
+		Allowed:    values[0] == 1,
+		Limit:      capacity,
+		Remaining:  values[1],
+		RetryAfter: time.Duration(values[2]) * time.Millisecond,
+		ResetAfter: time.Duration(values[3]) * time.Millisecond,
+	}, nil
+}
+
+// Reset 清除key的限流记录
+func (l *Limiter) Reset(ctx context.Context, key string) error {
+	return l.client.Del(ctx, l.key(SlidingWindow, key), l.key(TokenBucket, key)).Err()
+}
+
+// key 使用hash tag保证集群模式下同一个key的不同算法位于同一个slot
+func (l *Limiter) key(algorithm Algorithm, key string) string {
+	return l.prefix + string(algorithm) + ":{" + key + "}"
+}
+
+// randomMember 同一毫秒内的多次请求需要不同的成员
+func randomMember() (string, error) {
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
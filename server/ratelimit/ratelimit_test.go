@@ -0,0 +1,119 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Domingor/go-blackbox/server/cache"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// newLimiter 返回限流器和推进redis时间的函数，脚本中的TIME取自miniredis
+func newLimiter(t *testing.T) (func(d time.Duration), *Limiter) {
+	server := miniredis.RunT(t)
+	now := time.Unix(1700000000, 0)
+	server.SetTime(now)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+	return func(d time.Duration) {
+		now = now.Add(d)
+		server.SetTime(now)
+	}, New(client)
+}
+
+func allow(t *testing.T, l *Limiter, key string, limit Limit) *Result {
+	t.Helper()
+	result, err := l.Allow(context.Background(), key, limit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestSlidingWindow(t *testing.T) {
+	advance, limiter := newLimiter(t)
+	limit := PerSecond(3)
+
+	for i := 0; i < 3; i++ {
+		if r := allow(t, limiter, "ip", limit); !r.Allowed || r.Remaining != int64(2-i) {
+			t.Errorf("request %d want allowed remaining %d but get %+v", i, 2-i, r)
+		}
+		advance(100 * time.Millisecond)
+	}
+	r := allow(t, limiter, "ip", limit)
+	if r.Allowed || r.RetryAfter != 700*time.Millisecond {
+		t.Errorf("4th request want rejected retry 700ms but get %+v", r)
+	}
+
+	// 第一个请求滑出窗口后放行一个
+	advance(700 * time.Millisecond)
+	if r = allow(t, limiter, "ip", limit); !r.Allowed {
+		t.Errorf("request after window slides should be allowed but get %+v", r)
+	}
+	if r = allow(t, limiter, "ip", limit); r.Allowed {
+		t.Errorf("window should be full again but get %+v", r)
+	}
+	if r = allow(t, limiter, "other", limit); !r.Allowed {
+		t.Error("keys should be limited separately")
+	}
+}
+
+func TestTokenBucket(t *testing.T) {
+	advance, limiter := newLimiter(t)
+	limit := Limit{Rate: 10, Period: time.Second, Burst: 5, Algorithm: TokenBucket}
+
+	for i := 0; i < 5; i++ {
+		if r := allow(t, limiter, "user", limit); !r.Allowed {
+			t.Fatalf("burst request %d should be allowed but get %+v", i, r)
+		}
+	}
+	r := allow(t, limiter, "user", limit)
+	if r.Allowed || r.Limit != 5 || r.RetryAfter != 100*time.Millisecond {
+		t.Errorf("empty bucket want rejected retry 100ms but get %+v", r)
+	}
+
+	// 每100ms补充一个令牌
+	advance(250 * time.Millisecond)
+	for i := 0; i < 2; i++ {
+		if r = allow(t, limiter, "user", limit); !r.Allowed {
+			t.Errorf("refilled request %d should be allowed but get %+v", i, r)
+		}
+	}
+	if r = allow(t, limiter, "user", limit); r.Allowed {
+		t.Errorf("bucket should be empty again but get %+v", r)
+	}
+}
+
+func TestReset(t *testing.T) {
+	_, limiter := newLimiter(t)
+	limit := PerMinute(1)
+
+	allow(t, limiter, "reset", limit)
+	if r := allow(t, limiter, "reset", limit); r.Allowed {
+		t.Fatal("second request should be rejected")
+	}
+	if err := limiter.Reset(context.Background(), "reset"); err != nil {
+		t.Fatal(err)
+	}
+	if r := allow(t, limiter, "reset", limit); !r.Allowed {
+		t.Error("request after reset should be allowed")
+	}
+}
+
+func TestInvalid(t *testing.T) {
+	_, limiter := newLimiter(t)
+	if _, err := limiter.Allow(context.Background(), "k", Limit{Rate: 1}); err == nil {
+		t.Error("limit without period should fail")
+	}
+	if _, err := limiter.Allow(context.Background(), "k", Limit{Rate: 1, Period: time.Microsecond, Algorithm: TokenBucket}); err == nil {
+		t.Error("period under 1ms should fail")
+	}
+	if _, err := limiter.Allow(context.Background(), "k", Limit{Rate: 1, Period: time.Second, Algorithm: "leaky"}); err == nil {
+		t.Error("unknown algorithm should fail")
+	}
+	if _, err := FromCache(cache.NewMemoryCache(context.Background())); err == nil {
+		t.Error("memory cache should not support rate limiting")
+	}
+}
@@ -0,0 +1,8 @@
+package webiris
+
+// WebConfig web服务配置
+type WebConfig struct {
+	Listen     string `mapstructure:"listen" json:"listen" yaml:"listen"`             // 监听端口地址 如 :9528
+	DebugLevel string `mapstructure:"debugLevel" json:"debugLevel" yaml:"debugLevel"` // iris日志级别
+	TimeFormat string `mapstructure:"timeFormat" json:"timeFormat" yaml:"timeFormat"` // 时间格式化
+}
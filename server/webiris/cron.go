@@ -0,0 +1,119 @@
+package webiris
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Domingor/go-blackbox/server/cronjobs"
+	"github.com/kataras/iris/v12"
+)
+
+/**
+* @Description: 定时任务管理接口，按需挂载到需要鉴权的路由组下
+ */
+
+// MaxCronPreview 预览执行时间的最大次数
+const MaxCronPreview = 100
+
+// CronRoutes 注册定时任务管理路由
+//
+//	GET    /jobs              任务列表
+//	GET    /jobs/{name}       任务详情
+//	POST   /jobs/{name}/pause 暂停
+//	POST   /jobs/{name}/resume 恢复
+//	POST   /jobs/{name}/run   立即执行（异步）
+//	DELETE /jobs/{name}       删除
+//	GET    /preview?spec=&n=  校验表达式并预览后n次执行时间，n为1~100，默认5
+//	GET    /history?job=&status=&since=&until=&offset=&limit= 执行记录，时间为RFC3339格式
+func CronRoutes(party iris.Party, registry *cronjobs.Registry) {
+	party.Get("/jobs", func(ctx iris.Context) {
+		_ = ctx.JSON(registry.List())
+	})
+	party.Get("/jobs/{name}", func(ctx iris.Context) {
+		info, err := registry.Get(ctx.Params().Get("name"))
+		if err != nil {
+			stopWithCronError(ctx, err)
+			return
+		}
+		_ = ctx.JSON(info)
+	})
+	party.Post("/jobs/{name}/pause", cronAction(registry.Pause))
+	party.Post("/jobs/{name}/resume", cronAction(registry.Resume))
+	party.Delete("/jobs/{name}", cronAction(registry.Remove))
+	party.Post("/jobs/{name}/run", func(ctx iris.Context) {
+		name := ctx.Params().Get("name")
+		if _, err := registry.Get(name); err != nil {
+			stopWithCronError(ctx, err)
+			return
+		}
+		// 任务可能执行较久，不阻塞请求，也不随请求结束而取消，进程退出时随注册表上下文取消
+		go func() { _ = registry.RunNow(context.Background(), name) }()
+		ctx.StatusCode(iris.StatusAccepted)
+	})
+	party.Get("/preview", func(ctx iris.Context) {
+		n := ctx.URLParamIntDefault("n", 5)
+		if n < 1 || n > MaxCronPreview {
+			ctx.StopWithJSON(iris.StatusBadRequest, iris.Map{"error": fmt.Sprintf("n must be between 1 and %d", MaxCronPreview)})
+			return
+		}
+		runs, err := cronjobs.Preview(ctx.URLParam("spec"), time.Now(), n)
+		if err != nil {
+			ctx.StopWithJSON(iris.StatusBadRequest, iris.Map{"error": err.Error()})
+			return
+		}
+		_ = ctx.JSON(iris.Map{"next": runs})
+	})
+	party.Get("/history", func(ctx iris.Context) {
+		q := cronjobs.HistoryQuery{
+			Job:    ctx.URLParam("job"),
+			Status: ctx.URLParam("status"),
+			Offset: ctx.URLParamIntDefault("offset", 0),
+			Limit:  ctx.URLParamIntDefault("limit", cronjobs.DefaultHistoryLimit),
+		}
+		var err error
+		if q.Since, err = parseTimeParam(ctx, "since"); err == nil {
+			q.Until, err = parseTimeParam(ctx, "until")
+		}
+		if err != nil {
+			ctx.StopWithJSON(iris.StatusBadRequest, iris.Map{"error": err.Error()})
+			return
+		}
+		items, total, err := registry.History().Query(ctx.Request().Context(), q)
+		if err != nil {
+			stopWithCronError(ctx, err)
+			return
+		}
+		_ = ctx.JSON(iris.Map{"total": total, "items": items})
+	})
+}
+
+// parseTimeParam 解析RFC3339格式的时间参数，未传时返回零值
+func parseTimeParam(ctx iris.Context, name string) (time.Time, error) {
+	value := ctx.URLParam(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// cronAction 按名称操作任务
+func cronAction(action func(name string) error) iris.Handler {
+	return func(ctx iris.Context) {
+		if err := action(ctx.Params().Get("name")); err != nil {
+			stopWithCronError(ctx, err)
+			return
+		}
+		ctx.StatusCode(iris.StatusNoContent)
+	}
+}
+
+// stopWithCronError 任务不存在返回404，其他错误返回500
+func stopWithCronError(ctx iris.Context, err error) {
+	status := iris.StatusInternalServerError
+	if errors.Is(err, cronjobs.ErrJobNotFound) {
+		status = iris.StatusNotFound
+	}
+	ctx.StopWithJSON(status, iris.Map{"error": err.Error()})
+}
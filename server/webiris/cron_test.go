@@ -0,0 +1,86 @@
+package webiris
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/Domingor/go-blackbox/server/cronjobs"
+	"github.com/kataras/iris/v12"
+	"github.com/robfig/cron/v3"
+)
+
+func TestCronRoutes(t *testing.T) {
+	registry := cronjobs.NewRegistry(cron.New(cron.WithSeconds()))
+	ran := make(chan struct{}, 1)
+	_ = registry.Add(cronjobs.Job{Name: "report", Spec: "0 0 8 * * *", Handler: func(ctx context.Context) error {
+		ran <- struct{}{}
+		return nil
+	}})
+
+	app := iris.New()
+	app.Logger().SetLevel("disable")
+	CronRoutes(app.Party("/admin/cron"), registry)
+	if err := app.Build(); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := doRequest(app, http.MethodGet, "/admin/cron/jobs", nil)
+	var list []cronjobs.JobInfo
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil || len(list) != 1 || list[0].Name != "report" {
+		t.Fatalf("unexpected list %s %v", rec.Body.String(), err)
+	}
+
+	if rec = doRequest(app, http.MethodPost, "/admin/cron/jobs/report/pause", nil); rec.Code != http.StatusNoContent {
+		t.Errorf("pause want 204 but get %d", rec.Code)
+	}
+	if info, _ := registry.Get("report"); !info.Paused {
+		t.Error("job should be paused")
+	}
+	if rec = doRequest(app, http.MethodPost, "/admin/cron/jobs/report/run", nil); rec.Code != http.StatusAccepted {
+		t.Errorf("run want 202 but get %d", rec.Code)
+	}
+	<-ran
+	if rec = doRequest(app, http.MethodPost, "/admin/cron/jobs/missing/resume", nil); rec.Code != http.StatusNotFound {
+		t.Errorf("missing job want 404 but get %d", rec.Code)
+	}
+
+	if rec = doRequest(app, http.MethodGet, "/admin/cron/preview?spec=0+0+8+*+*+*&n=3", nil); rec.Code != http.StatusOK {
+		t.Errorf("preview want 200 but get %d", rec.Code)
+	}
+	var preview struct{ Next []string }
+	_ = json.Unmarshal(rec.Body.Bytes(), &preview)
+	if len(preview.Next) != 3 {
+		t.Errorf("preview want 3 runs but get %s", rec.Body.String())
+	}
+	if rec = doRequest(app, http.MethodGet, "/admin/cron/preview?spec=bad", nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid spec want 400 but get %d", rec.Code)
+	}
+	for _, n := range []string{"-1", "0", "101"} {
+		if rec = doRequest(app, http.MethodGet, "/admin/cron/preview?spec=@daily&n="+n, nil); rec.Code != http.StatusBadRequest {
+			t.Errorf("preview n=%s want 400 but get %d", n, rec.Code)
+		}
+	}
+
+	// 异步执行的记录在处理函数返回后写入
+	deadline := time.Now().Add(time.Second)
+	for {
+		if _, total, _ := registry.History().Query(context.Background(), cronjobs.HistoryQuery{}); total > 0 || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	rec = doRequest(app, http.MethodGet, "/admin/cron/history?job=report&status=success&limit=1", nil)
+	var history struct {
+		Total int64
+		Items []cronjobs.Execution
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &history); err != nil || history.Total != 1 || len(history.Items) != 1 {
+		t.Errorf("unexpected history %s %v", rec.Body.String(), err)
+	}
+	if rec = doRequest(app, http.MethodGet, "/admin/cron/history?since=yesterday", nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid since want 400 but get %d", rec.Code)
+	}
+}
@@ -36,6 +36,9 @@ func Init(timeFormat, port, logLevel string, components PartyComponent) *WebIris
 	// 一个可以让程序从任意的 http-relative panics 中恢复过来，
 	application.Use(recover.New())
 
+	// 请求ID，用于关联同一请求的日志
+	application.Use(TraceID())
+
 	// 日志级别
 	application.Logger().SetLevel(logLevel)
 
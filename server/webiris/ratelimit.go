@@ -0,0 +1,97 @@
+package webiris
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Domingor/go-blackbox/apputils/apptoken"
+	"github.com/Domingor/go-blackbox/server/ratelimit"
+	"github.com/Domingor/go-blackbox/server/zaplog"
+	"github.com/kataras/iris/v12"
+)
+
+/**
+* @Description: 基于 ratelimit 的限流中间件，支持按IP、JWT用户、API Key限流及按路由单独配置
+ */
+
+// KeyFunc 提取限流维度，返回空字符串表示不限流
+type KeyFunc func(ctx iris.Context) string
+
+// KeyByIP 按客户端IP限流
+func KeyByIP(ctx iris.Context) string {
+	return "ip:" + ctx.RemoteAddr()
+}
+
+// KeyByUser 按JWT中的用户ID限流，未携带有效token时退化为按IP限流
+func KeyByUser(ctx iris.Context) string {
+	auth := ctx.GetHeader("Authorization")
+	if token := strings.TrimPrefix(auth, "Bearer "); token != auth && token != "" {
+		if claim, err := apptoken.VerifyToken(token); err == nil {
+			return "user:" + strconv.FormatInt(claim.UserID, 10)
+		}
+	}
+	return KeyByIP(ctx)
+}
+
+// KeyByAPIKey 按请求头中的API Key限流，未携带时退化为按IP限流
+func KeyByAPIKey(header string) KeyFunc {
+	return func(ctx iris.Context) string {
+		if key := ctx.GetHeader(header); key != "" {
+			return "apikey:" + key
+		}
+		return KeyByIP(ctx)
+	}
+}
+
+// RateLimitOptions 限流中间件配置
+type RateLimitOptions struct {
+	Limit   ratelimit.Limit            // 默认规则，Rate为0时只对Routes中的路由限流
+	Routes  map[string]ratelimit.Limit // 路由单独规则，key为 "GET /users/{id:uint64}" 形式的注册路由
+	KeyFunc KeyFunc                    // 限流维度，默认 KeyByIP
+}
+
+// RateLimit 限流中间件，超出限制返回429并设置 Retry-After，redis不可用时放行
+func RateLimit(limiter *ratelimit.Limiter, opts RateLimitOptions) iris.Handler {
+	keyFunc := opts.KeyFunc
+	if keyFunc == nil {
+		keyFunc = KeyByIP
+	}
+
+	return func(ctx iris.Context) {
+		limit, route := opts.Limit, "*"
+		if r := ctx.GetCurrentRoute(); r != nil {
+			if l, ok := opts.Routes[r.Method()+" "+r.Path()]; ok {
+				limit, route = l, r.Method()+" "+r.Path()
+			}
+		}
+		key := keyFunc(ctx)
+		if limit.Rate <= 0 || key == "" {
+			ctx.Next()
+			return
+		}
+
+		result, err := limiter.Allow(ctx.Request().Context(), route+":"+key, limit)
+		if err != nil {
+			zaplog.SugaredLogger.Warnf("rate limit %s failed, request allowed: %s", key, err)
+			ctx.Next()
+			return
+		}
+
+		ctx.Header("X-RateLimit-Limit", strconv.FormatInt(result.Limit, 10))
+		ctx.Header("X-RateLimit-Remaining", strconv.FormatInt(result.Remaining, 10))
+		ctx.Header("X-RateLimit-Reset", strconv.FormatInt(ceilSeconds(result.ResetAfter), 10))
+		if !result.Allowed {
+			ctx.Header("Retry-After", strconv.FormatInt(ceilSeconds(result.RetryAfter), 10))
+			ctx.StopWithStatus(iris.StatusTooManyRequests)
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// ceilSeconds 向上取整到秒
+func ceilSeconds(d time.Duration) int64 {
+	return int64(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,130 @@
+package webiris
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Domingor/go-blackbox/apputils/apptoken"
+	"github.com/Domingor/go-blackbox/server/ratelimit"
+	"github.com/Domingor/go-blackbox/server/zaplog"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/kataras/iris/v12"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+)
+
+func TestMain(m *testing.M) {
+	zaplog.Logger = zap.NewNop()
+	zaplog.SugaredLogger = zaplog.Logger.Sugar()
+	os.Exit(m.Run())
+}
+
+func newRateLimitApp(t *testing.T, opts RateLimitOptions) (*miniredis.Miniredis, http.Handler) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { _ = client.Close() })
+
+	app := iris.New()
+	app.Logger().SetLevel("disable")
+	app.Use(RateLimit(ratelimit.New(client), opts))
+	ok := func(ctx iris.Context) { _, _ = ctx.WriteString("ok") }
+	app.Get("/public", ok)
+	app.Post("/login", ok)
+	if err := app.Build(); err != nil {
+		t.Fatal(err)
+	}
+	return server, app
+}
+
+func doRequest(handler http.Handler, method, path string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRateLimit(t *testing.T) {
+	_, app := newRateLimitApp(t, RateLimitOptions{
+		Limit:  ratelimit.PerMinute(2),
+		Routes: map[string]ratelimit.Limit{"POST /login": ratelimit.PerMinute(1)},
+	})
+
+	for i := 0; i < 2; i++ {
+		if rec := doRequest(app, http.MethodGet, "/public", nil); rec.Code != http.StatusOK {
+			t.Fatalf("request %d want 200 but get %d", i, rec.Code)
+		}
+	}
+	rec := doRequest(app, http.MethodGet, "/public", nil)
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("want 429 but get %d", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") != "60" || rec.Header().Get("X-RateLimit-Limit") != "2" ||
+		rec.Header().Get("X-RateLimit-Remaining") != "0" {
+		t.Errorf("unexpected headers %v", rec.Header())
+	}
+
+	// 路由单独计数
+	if rec = doRequest(app, http.MethodPost, "/login", nil); rec.Code != http.StatusOK {
+		t.Errorf("login want 200 but get %d", rec.Code)
+	}
+	if rec = doRequest(app, http.MethodPost, "/login", nil); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("second login want 429 but get %d", rec.Code)
+	}
+}
+
+func TestRateLimitKeyFunc(t *testing.T) {
+	_, app := newRateLimitApp(t, RateLimitOptions{Limit: ratelimit.PerMinute(1), KeyFunc: KeyByUser})
+
+	token, _, err := apptoken.GenToken(7, "homelander@vought.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := http.Header{"Authorization": {"Bearer " + token}}
+	if rec := doRequest(app, http.MethodGet, "/public", auth); rec.Code != http.StatusOK {
+		t.Fatalf("user request want 200 but get %d", rec.Code)
+	}
+	if rec := doRequest(app, http.MethodGet, "/public", auth); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("second user request want 429 but get %d", rec.Code)
+	}
+	// 同一IP的匿名请求单独计数
+	if rec := doRequest(app, http.MethodGet, "/public", nil); rec.Code != http.StatusOK {
+		t.Errorf("anonymous request want 200 but get %d", rec.Code)
+	}
+}
+
+func TestRateLimitFailOpen(t *testing.T) {
+	server, app := newRateLimitApp(t, RateLimitOptions{Limit: ratelimit.PerMinute(1)})
+	server.Close()
+
+	for i := 0; i < 3; i++ {
+		if rec := doRequest(app, http.MethodGet, "/public", nil); rec.Code != http.StatusOK {
+			t.Errorf("request should pass when redis is down but get %d", rec.Code)
+		}
+	}
+}
+
+func TestKeyByAPIKey(t *testing.T) {
+	_, app := newRateLimitApp(t, RateLimitOptions{Limit: ratelimit.PerMinute(1), KeyFunc: KeyByAPIKey("X-Api-Key")})
+
+	for _, key := range []string{"a", "b"} {
+		if rec := doRequest(app, http.MethodGet, "/public", http.Header{"X-Api-Key": {key}}); rec.Code != http.StatusOK {
+			t.Errorf("api key %s want 200 but get %d", key, rec.Code)
+		}
+	}
+	if rec := doRequest(app, http.MethodGet, "/public", http.Header{"X-Api-Key": {"a"}}); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("second request of api key a want 429 but get %d", rec.Code)
+	}
+}
+
+func TestCeilSeconds(t *testing.T) {
+	if ceilSeconds(1500*time.Millisecond) != 2 || ceilSeconds(0) != 0 {
+		t.Error("ceilSeconds should round up")
+	}
+}
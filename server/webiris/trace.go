@@ -0,0 +1,41 @@
+package webiris
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"regexp"
+
+	"github.com/Domingor/go-blackbox/server/zaplog"
+	"github.com/kataras/iris/v12"
+)
+
+// TraceIDHeader 请求ID请求头及响应头
+const TraceIDHeader = "X-Request-ID"
+
+// MaxTraceIDLength 请求头中请求ID的最大长度
+const MaxTraceIDLength = 64
+
+// traceIDPattern 请求头中允许的请求ID字符
+var traceIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// TraceID 读取请求头中的请求ID，没有、超过64个字符或包含字母、数字、._-以外的字符时重新生成，
+// 写入响应头及 ctx.Request().Context()，通过 db.WithContext(ctx.Request().Context()) 执行的SQL日志会携带该ID
+func TraceID() iris.Handler {
+	return func(ctx iris.Context) {
+		id := ctx.GetHeader(TraceIDHeader)
+		if len(id) > MaxTraceIDLength || !traceIDPattern.MatchString(id) {
+			id = newTraceID()
+		}
+		ctx.Header(TraceIDHeader, id)
+		r := ctx.Request()
+		ctx.ResetRequest(r.WithContext(zaplog.WithTraceID(r.Context(), id)))
+		ctx.Next()
+	}
+}
+
+// newTraceID 随机生成32位十六进制请求ID
+func newTraceID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,40 @@
+package webiris
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Domingor/go-blackbox/server/zaplog"
+	"github.com/kataras/iris/v12"
+)
+
+func TestTraceID(t *testing.T) {
+	app := iris.New()
+	app.Logger().SetLevel("disable")
+	app.Use(TraceID())
+	app.Get("/", func(ctx iris.Context) { _, _ = ctx.WriteString(zaplog.TraceID(ctx.Request().Context())) })
+	if err := app.Build(); err != nil {
+		t.Fatal(err)
+	}
+
+	header := http.Header{}
+	header.Set(TraceIDHeader, "req-1")
+	rec := doRequest(app, http.MethodGet, "/", header)
+	if rec.Body.String() != "req-1" || rec.Header().Get(TraceIDHeader) != "req-1" {
+		t.Errorf("request id should be kept but get %q %q", rec.Body.String(), rec.Header().Get(TraceIDHeader))
+	}
+	rec = doRequest(app, http.MethodGet, "/", nil)
+	if id := rec.Body.String(); len(id) != 32 || rec.Header().Get(TraceIDHeader) != id {
+		t.Errorf("request id should be generated but get %q", id)
+	}
+
+	// 不合法的请求ID重新生成
+	for _, invalid := range []string{"a b", "id\x00", "<script>", strings.Repeat("a", MaxTraceIDLength+1)} {
+		header.Set(TraceIDHeader, invalid)
+		rec = doRequest(app, http.MethodGet, "/", header)
+		if id := rec.Body.String(); len(id) != 32 || id == invalid || rec.Header().Get(TraceIDHeader) != id {
+			t.Errorf("invalid request id %q should be replaced but get %q", invalid, id)
+		}
+	}
+}
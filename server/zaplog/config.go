@@ -25,6 +25,6 @@ type Zap struct {
 	EncodeLevel      string `mapstructure:"encode-level" json:"encode-level" yaml:"encode-level"`
 	StacktraceKey    string `mapstructure:"stacktrace-key" json:"stacktrace-key" yaml:"stacktrace-key"`
 	LogInConsole     bool   `mapstructure:"log-in-console" json:"log-in-console" yaml:"log-in-console"`
-	MaxAge           int
-	WithRotationTime int
+	MaxAge           int    `mapstructure:"max-age" json:"max-age" yaml:"max-age"`                                  // 清除日志时间/hour
+	WithRotationTime int    `mapstructure:"with-rotation-time" json:"with-rotation-time" yaml:"with-rotation-time"` // 轮训生成日志间隔时间/hour
 }
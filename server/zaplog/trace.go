@@ -0,0 +1,20 @@
+package zaplog
+
+import "context"
+
+// traceIDKey 请求ID在ctx中的key
+type traceIDKey struct{}
+
+// WithTraceID ctx中记录请求ID，用于关联同一请求的日志
+func WithTraceID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, traceIDKey{}, id)
+}
+
+// TraceID 获取ctx中的请求ID，未设置时返回空字符串
+func TraceID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(traceIDKey{}).(string)
+	return id
+}
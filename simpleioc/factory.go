@@ -86,11 +86,16 @@ func GetContext() *GlobalContext {
 	return get
 }
 
-// GetCache 获取redis实例
+// GetCache 获取缓存实例，redis或内存实现
 func GetCache() cache.Rediser {
 
-	get := Get((*cache.RedisCache)(nil))
-	return get
+	if get := Get((*cache.RedisCache)(nil)); get != nil {
+		return get
+	}
+	if get := Get((*cache.MemoryCache)(nil)); get != nil {
+		return get
+	}
+	return nil
 }
 
 // GetCronJobInstance 获取定时任务实例